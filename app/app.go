@@ -1,15 +1,19 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"net/http"
-	"os"
-	"path"
 )
 
 type App struct {
 	sheetService    *SheetService
 	navSheetService *NavSheetService
+	reviewLog       *ReviewLog
+	trash           *TrashService
+	store           Store
+	// patternFile is where the patterns are saved, the patterns live in the store when it is empty
+	patternFile string
 }
 
 func Handler(mux *http.ServeMux, dir string, patternFile string) http.Handler {
@@ -18,21 +22,47 @@ func Handler(mux *http.ServeMux, dir string, patternFile string) http.Handler {
 	return mux
 }
 
+// NewApp runs the app on the markdown files of the dir directory
+// when dir is a git repository every change of the files gets committed, without a working git the history is off
 func NewApp(dir string, patternFile string) *App {
-	// Try to load pattern from JSON file, fallback to provided pattern
-	loadedPattern, err := LoadPatternFromJSON(patternFile)
-	if err != nil {
-		// If loading fails, use default pattern and try to save it
-		loadedPattern = RemindPattern{1, 1, 2, 3, 5, 8, 13, 21, 34, 55, 89}
-		if saveErr := SavePatternToJSON(patternFile, loadedPattern); saveErr != nil {
-			// Log error but don't fail startup
-			fmt.Printf("Warning: failed to save pattern to %s: %v\n", patternFile, saveErr)
+	if isGitRepository(dir) {
+		store, err := NewGitStore(dir)
+		if err == nil {
+			return NewAppWithStore(store, patternFile)
 		}
+		fmt.Printf("Warning: history is off, %s cannot be used as a git repository: %v\n", dir, err)
+	}
+	return NewAppWithStore(NewFileStore(dir), patternFile)
+}
+
+// NewAppWithStore runs the app on the documents of the store, such as a MemoryStore to keep off the disk
+// an empty patternFile keeps the patterns in the store as well
+func NewAppWithStore(store Store, patternFile string) *App {
+	// Try to load patterns from JSON file, fallback to the default patterns
+	var loadedPatterns PatternSet
+	var err error
+	if patternFile != "" {
+		loadedPatterns, err = LoadPatternsFromJSON(patternFile)
+	} else {
+		loadedPatterns, err = LoadPatternsFromStore(store)
+	}
+	if err != nil {
+		// If loading fails, run on the default patterns but leave the file alone so it can be fixed by hand,
+		// the next save from the pattern editor replaces it
+		fmt.Printf("Warning: failed to load patterns from %s, using the default patterns: %v\n", patternFile, err)
+		loadedPatterns = defaultPatternSet()
 	}
 
+	reviewLog, err := NewReviewLog(store)
+	if err != nil {
+		panic(err)
+	}
+
+	// the scheduler is set up by ReadDir, along with the date missed reviews count from
 	sheetSerice := &SheetService{
-		pattern: loadedPattern,
-		dir:     dir,
+		patterns:  loadedPatterns,
+		store:     store,
+		reviewLog: reviewLog,
 	}
 	err = sheetSerice.ReadDir()
 	if err != nil {
@@ -40,11 +70,7 @@ func NewApp(dir string, patternFile string) *App {
 	}
 
 	navSheetService := &NavSheetService{
-		dir: path.Join(dir, "nav"),
-	}
-	// Create nav directory if it doesn't exist
-	if err := os.MkdirAll(navSheetService.dir, 0755); err != nil {
-		fmt.Printf("Warning: failed to create nav directory: %v\n", err)
+		store: store,
 	}
 	err = navSheetService.ReadDir()
 	if err != nil {
@@ -52,8 +78,18 @@ func NewApp(dir string, patternFile string) *App {
 		fmt.Printf("Warning: failed to read nav directory: %v\n", err)
 	}
 
-	return &App{
+	app := &App{
 		sheetService:    sheetSerice,
 		navSheetService: navSheetService,
+		reviewLog:       reviewLog,
+		trash:           &TrashService{store: store, retention: defaultTrashRetention},
+		store:           store,
+		patternFile:     patternFile,
+	}
+	if err := app.watchStore(context.Background()); err != nil {
+		// the app still works, changes made outside it show up after a restart
+		fmt.Printf("Warning: failed to watch the sheets: %v\n", err)
 	}
+	go app.purgeTrashEvery(context.Background(), trashPurgeInterval)
+	return app
 }
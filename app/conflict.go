@@ -0,0 +1,124 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+// EditConflict is returned for an edit made on a version of a sheet that was changed in the meantime,
+// by another tab or outside the app
+type EditConflict struct {
+	// Saved is the content saved since the edit form was opened
+	Saved string
+}
+
+func (e *EditConflict) Error() string {
+	return "the sheet was changed since it was opened for editing"
+}
+
+// checkVersion refuses content for the document of the key unless it was edited on the saved version,
+// an empty version skips the check and so does content that is already saved
+func checkVersion(store Store, key string, version string, content string) error {
+	if version == "" {
+		return nil
+	}
+	saved, err := store.Get(key)
+	if err != nil {
+		return err
+	}
+	if models.ContentVersion(string(saved)) != version && string(saved) != content {
+		return &EditConflict{Saved: string(saved)}
+	}
+	return nil
+}
+
+// maxMergeCells caps the table of the line diff of a merge, which takes a cell for every pair of changed lines
+const maxMergeCells = 1 << 20
+
+// mergeVersions puts the saved and the submitted content together line by line, the lines both have are kept once
+// and so are the lines only one of them has; where both changed the same place, both sides are kept between
+// conflict markers to be sorted out by hand
+func mergeVersions(saved string, yours string) string {
+	// browsers send the lines of a textarea with \r\n, the files edited elsewhere usually have \n
+	a := strings.Split(strings.ReplaceAll(saved, "\r\n", "\n"), "\n")
+	b := strings.Split(strings.ReplaceAll(yours, "\r\n", "\n"), "\n")
+
+	// the lines both start and end with are kept as they are, only the lines in between are diffed
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	merged := append([]string{}, a[:prefix]...)
+	merged = append(merged, mergeLines(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	merged = append(merged, a[len(a)-suffix:]...)
+	return strings.Join(merged, "\n")
+}
+
+// mergeLines merges the lines of a and b by their longest common subsequence
+// lines too many to diff within maxMergeCells are not merged, both sides are kept whole between conflict markers
+func mergeLines(a []string, b []string) []string {
+	if (len(a)+1)*(len(b)+1) > maxMergeCells {
+		return conflictLines(a, b)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var merged, fromSaved, fromYours []string
+	flush := func() {
+		merged = append(merged, conflictLines(fromSaved, fromYours)...)
+		fromSaved, fromYours = nil, nil
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			flush()
+			merged = append(merged, a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			fromSaved = append(fromSaved, a[i])
+			i++
+		default:
+			fromYours = append(fromYours, b[j])
+			j++
+		}
+	}
+	flush()
+	return merged
+}
+
+// conflictLines returns the lines only one side changed as they are, and the lines both changed between conflict markers
+func conflictLines(fromSaved []string, fromYours []string) []string {
+	switch {
+	case len(fromSaved) == 0:
+		return fromYours
+	case len(fromYours) == 0:
+		return fromSaved
+	}
+	lines := []string{"<<<<<<< saved"}
+	lines = append(lines, fromSaved...)
+	lines = append(lines, "=======")
+	lines = append(lines, fromYours...)
+	return append(lines, ">>>>>>> yours")
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeVersions(t *testing.T) {
+	tests := []struct {
+		name  string
+		saved string
+		yours string
+		want  string
+	}{
+		{"same", "a\nb", "a\nb", "a\nb"},
+		{"added on each side", "a\nsaved\nb\nc", "a\nb\nyours\nc", "a\nsaved\nb\nyours\nc"},
+		{"line only saved has", "a\nb\nc", "a\nc", "a\nb\nc"},
+		{"changed on both sides", "a\nsaved\nc", "a\nyours\nc", "a\n<<<<<<< saved\nsaved\n=======\nyours\n>>>>>>> yours\nc"},
+		{"crlf from the browser", "a\nb", "a\r\nb\r\nc", "a\nb\nc"},
+		{"empty saved", "", "a", "<<<<<<< saved\n\n=======\na\n>>>>>>> yours"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeVersions(tt.saved, tt.yours); got != tt.want {
+				t.Errorf("mergeVersions(%q, %q) = %q, want %q", tt.saved, tt.yours, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeVersionsOfLongSheetsKeepsBothSides(t *testing.T) {
+	var saved, yours []string
+	for i := 0; i < 2000; i++ {
+		saved = append(saved, "saved "+strings.Repeat("x", i%7))
+		yours = append(yours, "yours "+strings.Repeat("y", i%5))
+	}
+	// the shared first and last lines stay out of the conflict
+	savedText := "top\n" + strings.Join(saved, "\n") + "\nbottom"
+	yoursText := "top\n" + strings.Join(yours, "\n") + "\nbottom"
+
+	want := "top\n<<<<<<< saved\n" + strings.Join(saved, "\n") + "\n=======\n" + strings.Join(yours, "\n") + "\n>>>>>>> yours\nbottom"
+	if got := mergeVersions(savedText, yoursText); got != want {
+		t.Errorf("mergeVersions of 2000 changed lines on both sides did not keep both sides whole")
+	}
+}
@@ -0,0 +1,275 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// FileStore keeps the documents as files in a directory, the key is the path of the file relative to the directory
+// this is the markdown directory layout, 2025/dec-14.md and nav/shortcuts.md
+type FileStore struct {
+	dir       string
+	watchers  storeWatchers
+	watchOnce sync.Once
+}
+
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+// path converts a key to the path of its file, keys reaching outside the directory are rejected
+func (s *FileStore) path(key string) (string, error) {
+	relPath := filepath.FromSlash(key)
+	if key == "" || !filepath.IsLocal(relPath) {
+		return "", fmt.Errorf("invalid key: %s", key)
+	}
+	return filepath.Join(s.dir, relPath), nil
+}
+
+func (s *FileStore) List(prefix string) ([]StoreItem, error) {
+	root := s.dir
+	if prefix != "" {
+		var err error
+		if root, err = s.path(prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	var items []StoreItem
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// hidden entries such as .git or the temporary files of a write are not documents
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %v", err)
+		}
+		items = append(items, StoreItem{Key: filepath.ToSlash(relPath), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		if os.IsNotExist(err) && prefix != "" {
+			// nothing was written under the prefix yet
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Key < items[j].Key
+	})
+	return items, nil
+}
+
+func (s *FileStore) Get(key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+func (s *FileStore) Put(key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := writeFileContent(path, string(data)); err != nil {
+		return fmt.Errorf("failed to save %s, the previous version is kept: %v", key, err)
+	}
+	s.watchers.notify(StoreEvent{Key: key, Op: StorePut})
+	return nil
+}
+
+func (s *FileStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := deleteFile(path); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	s.watchers.notify(StoreEvent{Key: key, Op: StoreDelete})
+	return nil
+}
+
+// Append adds data to the end of the document, creating it if needed
+func (s *FileStore) Append(key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := appendFile(path, data); err != nil {
+		return fmt.Errorf("failed to save %s: %v", key, err)
+	}
+	s.watchers.notify(StoreEvent{Key: key, Op: StorePut})
+	return nil
+}
+
+// Watch reports the documents put and deleted through this store, and the files changed in the directory by anything else
+// such as an editor or a sync tool; the directory is watched with inotify or its platform equivalent from the first Watch on,
+// and polled every pollInterval when that is not available
+func (s *FileStore) Watch(ctx context.Context) (<-chan StoreEvent, error) {
+	events := s.watchers.add(ctx)
+	s.watchOnce.Do(s.watchDir)
+	return events, nil
+}
+
+// watchDir starts watching the directory for the lifetime of the store
+func (s *FileStore) watchDir() {
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		if err = s.watchDirs(watcher, s.dir); err != nil {
+			watcher.Close()
+		}
+	}
+	if err != nil {
+		fmt.Printf("Warning: cannot watch %s, polling it instead: %v\n", s.dir, err)
+		go s.poll()
+		return
+	}
+	go s.forward(watcher)
+}
+
+// pollInterval is how often the directory is scanned for changes when it cannot be watched
+const pollInterval = 2 * time.Second
+
+// watchDirs adds root and every directory below it to the watcher, skipping hidden ones such as .git
+func (s *FileStore) watchDirs(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+// forward turns the file system events into store events
+func (s *FileStore) forward(watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+	for {
+		select {
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			fmt.Printf("Warning: watching %s: %v\n", s.dir, err)
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			relPath, err := filepath.Rel(s.dir, event.Name)
+			if err != nil || strings.HasPrefix(filepath.Base(relPath), ".") {
+				continue
+			}
+			key := filepath.ToSlash(relPath)
+
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					// a new directory, such as the year directory of a synced sheet, is watched too
+					// and the files that landed in it before the watch started are reported
+					if err := s.watchDirs(watcher, event.Name); err != nil {
+						fmt.Printf("Warning: watching %s: %v\n", event.Name, err)
+					}
+					items, _ := s.List(key)
+					for _, item := range items {
+						s.watchers.notify(StoreEvent{Key: item.Key, Op: StorePut})
+					}
+					continue
+				}
+			}
+
+			switch {
+			case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+				// a renamed file is gone under its old name, the new name comes as a create
+				s.watchers.notify(StoreEvent{Key: key, Op: StoreDelete})
+			case event.Has(fsnotify.Create), event.Has(fsnotify.Write):
+				s.watchers.notify(StoreEvent{Key: key, Op: StorePut})
+			}
+		}
+	}
+}
+
+// poll scans the directory every pollInterval and reports the documents that changed since the last scan
+func (s *FileStore) poll() {
+	seen := make(map[string]time.Time)
+	if items, err := s.List(""); err == nil {
+		for _, item := range items {
+			seen[item.Key] = item.ModTime
+		}
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		items, err := s.List("")
+		if err != nil {
+			fmt.Printf("Warning: polling %s: %v\n", s.dir, err)
+			continue
+		}
+		current := make(map[string]time.Time, len(items))
+		for _, item := range items {
+			current[item.Key] = item.ModTime
+			if modTime, ok := seen[item.Key]; !ok || !modTime.Equal(item.ModTime) {
+				s.watchers.notify(StoreEvent{Key: item.Key, Op: StorePut})
+			}
+		}
+		for key := range seen {
+			if _, ok := current[key]; !ok {
+				s.watchers.notify(StoreEvent{Key: key, Op: StoreDelete})
+			}
+		}
+		seen = current
+	}
+}
+
+// appendFile adds data to the end of the file and syncs it, an append cannot be renamed into place
+// so a failed append can at most leave a torn last line, which the readers of appended files skip
+func appendFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
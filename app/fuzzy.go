@@ -0,0 +1,119 @@
+package app
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// fuzzySuffix marks a word of a search to also match the words spelled nearly the same, partion~ finds "partition"
+const fuzzySuffix = "~"
+
+// fuzzyWeight scales the score of a near match of one edit, a match of two edits gets half of it
+// a document matching a word only nearly ranks after the ones matching it exactly anyway
+const fuzzyWeight = 0.5
+
+// isFuzzy tells if the token of query is followed by the fuzzy suffix
+func isFuzzy(query string, t token) bool {
+	return strings.HasPrefix(query[t.end:], fuzzySuffix)
+}
+
+// hasFuzzyWords tells if any word of the query ends in the fuzzy suffix
+func hasFuzzyWords(query string) bool {
+	for _, token := range tokenize(query) {
+		if isFuzzy(query, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxEdits is how many edits a near match of the word may be away from it, more for longer words
+// words shorter than 3 letters only match exactly, anything would be near them
+func maxEdits(word string) int {
+	switch n := utf8.RuneCountInString(word); {
+	case n < 3:
+		return 0
+	case n <= 5:
+		return 1
+	}
+	return 2
+}
+
+// nearTerms returns the terms of the indexed words within maxEdits of word, and how many edits away each is
+// the caller must hold x.mu
+func (x *searchIndex) nearTerms(word string) map[string]int {
+	limit := maxEdits(word)
+	if limit == 0 {
+		return nil
+	}
+	exact := stem(word)
+	terms := make(map[string]int)
+	for indexed := range x.words {
+		term := stem(indexed)
+		if term == exact {
+			continue
+		}
+		distance := editDistance(word, indexed, limit)
+		if distance > limit {
+			continue
+		}
+		if previous, ok := terms[term]; !ok || distance < previous {
+			terms[term] = distance
+		}
+	}
+	return terms
+}
+
+// isNear tells if word is within maxEdits of any of the fuzzy words
+func isNear(word string, fuzzyWords []string) bool {
+	for _, fuzzyWord := range fuzzyWords {
+		if limit := maxEdits(fuzzyWord); limit > 0 && editDistance(word, fuzzyWord, limit) <= limit {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance counts the insertions, deletions, substitutions and swaps of adjacent letters turning a into b
+// it gives up on counting past limit, returning limit+1
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > limit {
+		return limit + 1
+	}
+
+	// rows of the distances between the prefixes of a and b, two rows back for the swaps
+	previous2 := make([]int, len(rb)+1)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		rowMin := current[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				current[j] = min(current[j], previous2[j-2]+1)
+			}
+			rowMin = min(rowMin, current[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		previous2, previous, current = previous, current, previous2
+	}
+	return min(previous[len(rb)], limit+1)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
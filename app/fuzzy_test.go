@@ -0,0 +1,47 @@
+package app
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"partition", "partition", 2, 0},
+		{"partion", "partition", 2, 2},
+		{"partiton", "partition", 2, 1},
+		{"patrition", "partition", 2, 1},
+		{"kitten", "sitting", 3, 3},
+		{"", "abc", 3, 3},
+		{"abc", "", 3, 3},
+		{"café", "cafe", 1, 1},
+		// past the limit the count stops at limit+1
+		{"kitten", "sitting", 2, 3},
+		{"a", "abcdef", 2, 3},
+		{"abcdef", "uvwxyz", 1, 2},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b, tt.limit); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestMaxEdits(t *testing.T) {
+	tests := []struct {
+		word string
+		want int
+	}{
+		{"go", 0},
+		{"sql", 1},
+		{"index", 1},
+		{"window", 2},
+		{"日本語", 1},
+	}
+	for _, tt := range tests {
+		if got := maxEdits(tt.word); got != tt.want {
+			t.Errorf("maxEdits(%q) = %d, want %d", tt.word, got, tt.want)
+		}
+	}
+}
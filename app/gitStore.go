@@ -0,0 +1,200 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+// GitStore is a FileStore whose directory is a git repository, every put and delete becomes a commit
+// so an accidental save or delete can be undone from the history of the document
+type GitStore struct {
+	*FileStore
+	// mu keeps the write and its commit together, git cannot run two commits at once anyway
+	mu sync.Mutex
+	// identity is passed to git when the repository and the user have no identity configured
+	identity []string
+}
+
+// revisionPattern matches the commit hashes accepted from requests, anything else could be read as a git option
+var revisionPattern = regexp.MustCompile(`^[0-9a-f]{4,40}$`)
+
+// isGitRepository tells if dir is the top of a git repository
+func isGitRepository(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil && info.IsDir()
+}
+
+// NewGitStore opens the git repository of dir, `git init` the directory first to turn it on
+func NewGitStore(dir string) (*GitStore, error) {
+	if !isGitRepository(dir) {
+		return nil, fmt.Errorf("%s is not a git repository", dir)
+	}
+	s := &GitStore{FileStore: NewFileStore(dir)}
+	if out, err := s.git("config", "user.email"); err != nil || strings.TrimSpace(string(out)) == "" {
+		s.identity = []string{"-c", "user.name=memory-sheets", "-c", "user.email=memory-sheets@localhost"}
+	}
+
+	// the documents written before history was turned on, or changed by hand meanwhile, need a revision to go back to
+	if _, err := s.git("add", "--all", "--", ".", ":(exclude)"+trashPrefix); err != nil {
+		return nil, err
+	}
+	if _, err := s.git("diff", "--cached", "--quiet"); err != nil {
+		if _, err := s.git(append(s.identity, "commit", "--quiet", "-m", "Track the existing sheets")...); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *GitStore) Put(key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.FileStore.Get(key)
+	created := errors.Is(err, fs.ErrNotExist)
+	if err := s.FileStore.Put(key, data); err != nil {
+		return err
+	}
+	if isTrashKey(key) {
+		// the history already has the deleted document
+		return nil
+	}
+	if created {
+		return s.commit(key, "Create "+describeKey(key))
+	}
+	return s.commit(key, "Update "+describeKey(key))
+}
+
+func (s *GitStore) Append(key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.FileStore.Get(key)
+	created := errors.Is(err, fs.ErrNotExist)
+	if err := s.FileStore.Append(key, data); err != nil {
+		return err
+	}
+	if created {
+		return s.commit(key, "Create "+describeKey(key))
+	}
+	return s.commit(key, "Update "+describeKey(key))
+}
+
+func (s *GitStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.FileStore.Delete(key); err != nil {
+		return err
+	}
+	if isTrashKey(key) {
+		return nil
+	}
+	return s.commit(key, "Delete "+describeKey(key))
+}
+
+// History returns the revisions of the document, latest first
+func (s *GitStore) History(key string) ([]models.Revision, error) {
+	out, err := s.git("log", "--format=%H%x1f%at%x1f%s", "--", key)
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []models.Revision
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 3 {
+			continue
+		}
+		unix, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		revisions = append(revisions, models.Revision{Hash: fields[0], Time: time.Unix(unix, 0), Message: fields[2]})
+	}
+	return revisions, nil
+}
+
+// Revision returns the content of the document as of the revision
+func (s *GitStore) Revision(key string, hash string) ([]byte, error) {
+	if !revisionPattern.MatchString(hash) {
+		return nil, fmt.Errorf("invalid revision: %s", hash)
+	}
+	return s.git("show", hash+":"+key)
+}
+
+// Diff returns the unified diff of the document from one revision to another
+// an empty from diffs the revision to against the one before it
+func (s *GitStore) Diff(key string, from string, to string) (string, error) {
+	if !revisionPattern.MatchString(to) || (from != "" && !revisionPattern.MatchString(from)) {
+		return "", errors.New("invalid revision")
+	}
+	var out []byte
+	var err error
+	if from == "" {
+		out, err = s.git("show", "--format=", "--no-color", to, "--", key)
+	} else {
+		out, err = s.git("diff", "--no-color", from, to, "--", key)
+	}
+	return string(out), err
+}
+
+// commit records the document as it is now in the working tree, the caller must hold s.mu
+func (s *GitStore) commit(key string, message string) error {
+	if _, err := s.git("add", "--all", "--", key); err != nil {
+		return fmt.Errorf("%s is saved but not committed to the history: %v", key, err)
+	}
+	// nothing staged means the content did not change, there is nothing to commit
+	if _, err := s.git("diff", "--cached", "--quiet", "--", key); err == nil {
+		return nil
+	}
+	if _, err := s.git(append(s.identity, "commit", "--quiet", "-m", message, "--", key)...); err != nil {
+		return fmt.Errorf("%s is saved but not committed to the history: %v", key, err)
+	}
+	return nil
+}
+
+func (s *GitStore) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = s.dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// describeKey names the document of the key for commit messages, such as "sheet 2025-12-14" or "nav sheet shortcuts"
+func describeKey(key string) string {
+	switch key {
+	case stateKey:
+		return "review state"
+	case reviewLogKey:
+		return "review log"
+	case patternKey:
+		return "patterns"
+	case overdueSinceKey:
+		return "overdue tracking"
+	}
+	if title, ok := strings.CutPrefix(key, navPrefix+"/"); ok {
+		return "nav sheet " + strings.TrimSuffix(title, ".md")
+	}
+	if sheet, err := parseSheetKey(StoreItem{Key: key}); err == nil && sheet != nil {
+		return "sheet " + sheet.ID()
+	}
+	return key
+}
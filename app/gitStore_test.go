@@ -0,0 +1,136 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestGitDir returns a new git repository, the test is skipped without git
+func newTestGitDir(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "--quiet", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v %s", err, out)
+	}
+	return dir
+}
+
+func TestGitStore(t *testing.T) {
+	dir := newTestGitDir(t)
+	// written before history was turned on
+	if err := os.WriteFile(filepath.Join(dir, "navigation.md"), []byte("links"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewGitStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history, err := store.History("navigation.md"); err != nil || len(history) != 1 || history[0].Message != "Track the existing sheets" {
+		t.Errorf("history of an existing document = %v, %v, want the tracking commit", history, err)
+	}
+
+	const key = "2025/dec-14.md"
+	changes := []func() error{
+		func() error { return store.Put(key, []byte("first\n")) },
+		func() error { return store.Put(key, []byte("second\n")) },
+		// the same content again has nothing to commit
+		func() error { return store.Put(key, []byte("second\n")) },
+		func() error { return store.Put(trashPrefix+"/20251214-100000/"+key, []byte("second\n")) },
+		func() error { return store.Delete(key) },
+	}
+	for _, change := range changes {
+		if err := change(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := store.History(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, revision := range history {
+		messages = append(messages, revision.Message)
+	}
+	want := []string{"Delete sheet 2025-12-14", "Update sheet 2025-12-14", "Create sheet 2025-12-14"}
+	if !slices.Equal(messages, want) {
+		t.Fatalf("history = %q, want %q", messages, want)
+	}
+
+	for i, content := range map[int]string{1: "second\n", 2: "first\n"} {
+		if data, err := store.Revision(key, history[i].Hash); err != nil || string(data) != content {
+			t.Errorf("revision %d = %q, %v, want %q", i, data, err, content)
+		}
+	}
+	diffs := map[string][2]string{
+		"against the revision before": {"", history[1].Hash},
+		"between two revisions":       {history[2].Hash, history[1].Hash},
+	}
+	for name, revisions := range diffs {
+		diff, err := store.Diff(key, revisions[0], revisions[1])
+		if err != nil || !strings.Contains(diff, "-first") || !strings.Contains(diff, "+second") {
+			t.Errorf("diff %s = %q, %v, want first replaced by second", name, diff, err)
+		}
+	}
+	if _, err := store.Revision(key, "--output=x"); err == nil {
+		t.Error("Revision of an option succeeded")
+	}
+	if _, err := store.Diff(key, "", "HEAD"); err == nil {
+		t.Error("Diff of a ref name succeeded")
+	}
+}
+
+func TestSheetHistory(t *testing.T) {
+	store, err := NewGitStore(newTestGitDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestSheetService(t, store)
+	sheet, err := s.CreateSheet(Today(), "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateSheet(sheet.ID(), "second", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := s.SheetHistory(sheet.ID())
+	if err != nil || len(history) != 2 {
+		t.Fatalf("history = %v, %v, want the create and the update", history, err)
+	}
+	if content, err := s.SheetRevision(sheet.ID(), history[1].Hash); err != nil || content != "first" {
+		t.Errorf("first revision = %q, %v, want %q", content, err, "first")
+	}
+	if diff, err := s.SheetDiff(sheet.ID(), "", history[0].Hash); err != nil || !strings.Contains(diff, "+second") {
+		t.Errorf("diff of the update = %q, %v", diff, err)
+	}
+}
+
+func TestNewAppWithoutGit(t *testing.T) {
+	dir := newTestGitDir(t)
+	// git is gone once the repository exists
+	t.Setenv("PATH", t.TempDir())
+
+	a := NewApp(dir, "")
+	if a.sheetService.HasHistory() {
+		t.Error("history is on without git")
+	}
+	sheet, err := a.sheetService.CreateSheet(Today(), "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.sheetService.SheetHistory(sheet.ID()); !errors.Is(err, errHistoryOff) {
+		t.Errorf("SheetHistory = %v, want %v", err, errHistoryOff)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, a.sheetService.fromDateToKey(sheet.Date, sheet.Seq))); err != nil || string(data) != "text" {
+		t.Errorf("written document = %q, %v", data, err)
+	}
+}
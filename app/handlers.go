@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,55 +15,71 @@ import (
 )
 
 // ShowTodaySheets handles GET /sheets - returns sheets that need to be reminded today
+// the optional tag query parameter narrows them down to the sheets carrying the tag
 func (a *App) ShowTodaySheets(vr *views.ViewRenderer) error {
 	today := Today()
 	remindingSheets, err := a.sheetService.LookUpSheets(today)
 	if err != nil {
 		return err
 	}
-	todaySheet, err := a.sheetService.GetSheetByDate(today)
-	if err != nil {
-		todaySheet = nil
+
+	// offer the tags of today's sheets as filters
+	seen := make(map[string]bool)
+	var tags []string
+	for _, sheet := range remindingSheets {
+		for _, tag := range sheet.Tags() {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+
+	tag := models.NormalizeTag(vr.Request().URL.Query().Get("tag"))
+	if tag != "" {
+		var taggedSheets []*models.MemorySheet
+		for _, sheet := range remindingSheets {
+			if sheet.HasTag(tag) {
+				taggedSheets = append(taggedSheets, sheet)
+			}
+		}
+		remindingSheets = taggedSheets
 	}
 
-	return vr.IndexPage(remindingSheets, todaySheet, a.navSheetService.ListSheets())
+	return vr.IndexPage(remindingSheets, a.sheetService.SheetsOfDate(today), a.navSheetService.ListSheets(), tags, tag, a.reviewedToday())
 }
 
 // ShowAllSheets handles GET /all-sheets - returns all sheets
 func (a *App) ShowAllSheets(vr *views.ViewRenderer) error {
-	todaySheet, err := a.sheetService.GetSheetByDate(Today())
-	if err != nil {
-		todaySheet = nil
-	}
-	return vr.IndexPage(a.sheetService.sheets, todaySheet, a.navSheetService.ListSheets())
+	return vr.IndexPage(a.sheetService.AllSheets(), a.sheetService.SheetsOfDate(Today()), a.navSheetService.ListSheets(), nil, "", a.reviewedToday())
+}
+
+// reviewedToday counts the reviews logged since the start of the local day
+func (a *App) reviewedToday() int {
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return len(a.reviewLog.Between(start, start.AddDate(0, 0, 1)))
 }
 
-// ShowEditSheet handles GET /sheets/{date}/edit - returns the edit page for a sheet
+// ShowEditSheet handles GET /sheets/{id}/edit - returns the edit page for a sheet
 func (a *App) ShowEditSheet(vr *views.ViewRenderer) error {
 	r := vr.Request()
-	dateStr := r.PathValue("date")
-	date, err := time.Parse(time.DateOnly, dateStr)
-	if err != nil {
-		return err
-	}
-	sheet, err := a.sheetService.GetSheetByDate(date)
+	sheet, err := a.sheetService.GetSheetByID(r.PathValue("id"))
 	if err != nil {
 		return err
 	}
 	content := sheet.Text
-	return vr.ShowEditSheet(dateStr, content)
+	return vr.ShowEditSheet(sheet.ID(), content)
 }
 
-// ShowSheet handles GET /sheets/{date} - returns a specific sheet by date
+// ShowSheet handles GET /sheets/{id} - returns a specific sheet, with how late its review is if it is reminded today
+// the optional q query parameter marks the matches of a search in it
 func (a *App) ShowSheet(vr *views.ViewRenderer) error {
 	r := vr.Request()
-	dateStr := r.PathValue("date")
-	if dateStr == "" {
-		return errors.New("date cannot be empty")
-	}
-	date, err := time.Parse(time.DateOnly, dateStr)
-	if err != nil {
-		return err
+	id := r.PathValue("id")
+	if id == "" {
+		return errors.New("id cannot be empty")
 	}
 	today := Today()
 	remindingSheets, err := a.sheetService.LookUpSheets(today)
@@ -69,163 +88,578 @@ func (a *App) ShowSheet(vr *views.ViewRenderer) error {
 	}
 	var current *models.MemorySheet
 	for _, rSheet := range remindingSheets {
-		if rSheet.Date.Equal(date) {
+		if rSheet.ID() == id {
 			current = rSheet
 			break
 		}
 	}
 	if current == nil {
-		return errors.New("note not found")
+		sheet, err := a.sheetService.GetSheetByID(id)
+		if err != nil {
+			return err
+		}
+		copied := *sheet
+		current = &copied
 	}
+	current.Highlight = searchHighlighter(r)
 	return vr.SheetComponent(current)
 }
 
+// HandleCreateSheet handles POST /sheets - creates a sheet of today
+// the optional date form value backfills a sheet of an earlier day instead
 func (a *App) HandleCreateSheet(vr *views.ViewRenderer) error {
 	r := vr.Request()
 
 	// Read request body
 	content := r.FormValue("content")
 
+	date := Today()
+	if dateStr := r.FormValue("date"); dateStr != "" {
+		var err error
+		date, err = time.Parse(time.DateOnly, dateStr)
+		if err != nil {
+			return errors.New("invalid date format")
+		}
+	}
+
+	// Create the sheet
+	sheet, err := a.sheetService.CreateSheet(date, content)
+	if err != nil {
+		return err
+	}
+	return vr.SheetComponent(sheet)
+}
+
+// HandleUpdateSheet handles PUT /sheets/{id} - updates an existing sheet
+// an edit made on an outdated version gets the conflict form instead, with both versions and a merge of them
+func (a *App) HandleUpdateSheet(vr *views.ViewRenderer) error {
+	r := vr.Request()
+	id := r.PathValue("id")
+	if id == "" {
+		return errors.New("id cannot be empty")
+	}
+
+	// Read request body
+	content := r.FormValue("content")
+
 	// Update the sheet
-	err := a.sheetService.CreateSheet(content)
+	err := a.sheetService.UpdateSheet(id, content, r.FormValue("version"))
+	var conflict *EditConflict
+	if errors.As(err, &conflict) {
+		return vr.ShowSheetConflict(id, conflict.Saved, content, mergeVersions(conflict.Saved, content))
+	}
 	if err != nil {
 		return err
 	}
 
-	sheet, err := a.sheetService.GetSheetByDate(Today())
+	sheet, err := a.sheetService.GetSheetByID(id)
 	if err != nil {
 		return err
 	}
 	return vr.SheetComponent(sheet)
 }
 
-// HandleUpdateSheet handles PUT /sheets/{date} - updates an existing sheet
-func (a *App) HandleUpdateSheet(vr *views.ViewRenderer) error {
+// HandleDeleteSheet handles DELETE /sheets/{id} - deletes a sheet
+func (a *App) HandleDeleteSheet(vr *views.ViewRenderer) error {
 	r := vr.Request()
-	dateStr := r.PathValue("date")
-	if dateStr == "" {
-		return errors.New("date cannot be empty")
+	id := r.PathValue("id")
+	if id == "" {
+		return errors.New("id cannot be empty")
 	}
 
-	date, err := time.Parse(time.DateOnly, dateStr)
+	// Delete the sheet
+	err := a.sheetService.DeleteSheet(id)
 	if err != nil {
-		return errors.New("invalid date format")
+		return err
 	}
 
-	// Read request body
-	content := r.FormValue("content")
+	return nil
+}
 
-	// Update the sheet
-	err = a.sheetService.UpdateSheet(date, content)
+// HandleReviewSheet handles POST /sheets/{id}/review - grades how well a sheet was remembered
+func (a *App) HandleReviewSheet(vr *views.ViewRenderer) error {
+	r := vr.Request()
+	id := r.PathValue("id")
+	if id == "" {
+		return errors.New("id cannot be empty")
+	}
+
+	grade, err := models.ParseGrade(r.FormValue("grade"))
 	if err != nil {
 		return err
 	}
 
-	sheet, err := a.sheetService.GetSheetByDate(date)
+	err = a.sheetService.ReviewSheet(id, grade)
 	if err != nil {
 		return err
 	}
+
+	sheet, err := a.sheetService.GetSheetByID(id)
+	if err != nil {
+		return err
+	}
+
+	// the sheet was rendered with the time it was shown, so the time spent on it can be logged
+	var timeSpent time.Duration
+	if shownAt, err := strconv.ParseInt(r.FormValue("shown_at"), 10, 64); err == nil {
+		timeSpent = max(0, time.Since(time.Unix(shownAt, 0)).Round(time.Second))
+	}
+	err = a.reviewLog.Append(models.ReviewEntry{
+		SheetID:    sheet.ID(),
+		SheetDate:  sheet.Date,
+		ReviewedAt: time.Now(),
+		Outcome:    grade,
+		TimeSpent:  timeSpent,
+	})
+	if err != nil {
+		// the grade is saved already, the review did happen even if the log misses it
+		fmt.Printf("Warning: %v\n", err)
+	}
+
 	return vr.SheetComponent(sheet)
 }
 
-// HandleDeleteSheet handles DELETE /sheets/{date} - deletes a sheet
-func (a *App) HandleDeleteSheet(vr *views.ViewRenderer) error {
+// ShowSheetHistory handles GET /sheets/{id}/history - lists the revisions and the reviews of a sheet
+// the to query parameter shows the changes of a revision, against the revision of the from query parameter when given
+func (a *App) ShowSheetHistory(vr *views.ViewRenderer) error {
 	r := vr.Request()
-	dateStr := r.PathValue("date")
-	if dateStr == "" {
-		return errors.New("date cannot be empty")
+	id := r.PathValue("id")
+	sheet, err := a.sheetService.GetSheetByID(id)
+	if err != nil {
+		return err
+	}
+	reviews := a.reviewLog.ForSheet(id)
+	if !a.sheetService.HasHistory() {
+		return vr.ShowSheetHistory(sheet, reviews, nil, false, "", "", "")
 	}
 
-	date, err := time.Parse(time.DateOnly, dateStr)
+	revisions, err := a.sheetService.SheetHistory(id)
 	if err != nil {
-		return errors.New("invalid date format")
+		return err
 	}
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	var diff string
+	if to != "" {
+		diff, err = a.sheetService.SheetDiff(id, from, to)
+		if err != nil {
+			return err
+		}
+	}
+	return vr.ShowSheetHistory(sheet, reviews, revisions, true, from, to, diff)
+}
 
-	// Delete the sheet
-	err = a.sheetService.DeleteSheet(date)
+// HandleRestoreSheet handles POST /sheets/{id}/history/{hash}/restore - saves an older revision of a sheet as its content
+// the restore is an update like any other, so it shows up in the history too and can itself be undone
+func (a *App) HandleRestoreSheet(vr *views.ViewRenderer) error {
+	r := vr.Request()
+	id := r.PathValue("id")
+	content, err := a.sheetService.SheetRevision(id, r.PathValue("hash"))
 	if err != nil {
 		return err
 	}
+	// restoring is meant to replace whatever is saved, so there is no version to check
+	if err := a.sheetService.UpdateSheet(id, content, ""); err != nil {
+		return err
+	}
 
+	sheet, err := a.sheetService.GetSheetByID(id)
+	if err != nil {
+		return err
+	}
+	http.Redirect(vr.ResponseWriter(), r, sheet.Url()+"/history", http.StatusSeeOther)
 	return nil
 }
 
+// ShowForecast handles GET /forecast - shows how many sheets come due on each of the next days
+// the optional name and pattern query parameters preview the forecast of a candidate pattern before it is saved
+func (a *App) ShowForecast(vr *views.ViewRenderer) error {
+	r := vr.Request()
+	days := 28
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		var err error
+		days, err = strconv.Atoi(daysStr)
+		if err != nil || days < 1 || days > 366 {
+			return errors.New("days must be a number from 1 to 366")
+		}
+	}
+
+	today := Today()
+	forecast := a.sheetService.Forecast(today, days)
+	names := a.sheetService.GetPatterns().Names()
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = models.DefaultPatternName
+	}
+	candidate := r.URL.Query().Get("pattern")
+	if candidate == "" {
+		return vr.ShowForecast(forecast, nil, days, names, name, "")
+	}
+	pattern, err := ParseRemindPattern(candidate)
+	if err != nil {
+		return err
+	}
+	preview, err := a.sheetService.PreviewForecast(name, pattern, today, days)
+	if err != nil {
+		return err
+	}
+	return vr.ShowForecast(preview, forecast, days, names, name, pattern.String())
+}
+
 // ShowChangePattern handles GET /change-pattern - shows the pattern editor
+// the name query parameter picks the pattern to edit, the default pattern otherwise
 func (a *App) ShowChangePattern(vr *views.ViewRenderer) error {
-	pattern := a.sheetService.GetPattern()
-	// Convert pattern to a map for easy lookup
-	selectedMap := make(map[int]bool)
-	for _, day := range pattern {
-		if day >= 1 && day <= 200 {
-			selectedMap[day] = true
-		}
+	patterns := a.sheetService.GetPatterns()
+	name := vr.Request().URL.Query().Get("name")
+	if _, ok := patterns[name]; !ok {
+		name = models.DefaultPatternName
+	}
+	pattern := patterns[name]
+
+	// Walk the intervals to mark the days a sheet gets reviewed on
+	reviewDays := make(map[int]bool)
+	day := 0
+	for step := 0; day <= 200; step++ {
+		day += pattern[min(step, len(pattern)-1)]
+		reviewDays[day] = true
 	}
-	return vr.ShowChangePattern(selectedMap)
+	return vr.ShowChangePattern(patterns.Names(), name, pattern.String(), reviewDays)
 }
 
-// HandlePostChangePattern handles POST /change-pattern - saves the pattern
+// HandlePostChangePattern handles POST /change-pattern - saves the comma separated intervals of the named pattern
 func (a *App) HandlePostChangePattern(vr *views.ViewRenderer) error {
 	r := vr.Request()
 	if err := r.ParseForm(); err != nil {
 		return fmt.Errorf("failed to parse form: %v", err)
 	}
+	name := r.PostForm.Get("name")
 
-	// Collect selected days from form
-	selectedDays := make(map[int]bool)
-	for key := range r.PostForm {
-		if strings.HasPrefix(key, "day_") {
-			var day int
-			if _, err := fmt.Sscanf(key, "day_%d", &day); err == nil {
-				if day >= 1 && day <= 200 {
-					selectedDays[day] = true
-				}
-			}
-		}
+	// Empty patterns and patterns ending in 0 are rejected here, they would break IsDateReminding
+	pattern, err := ParseRemindPattern(r.PostForm.Get("pattern"))
+	if err != nil {
+		return err
 	}
 
-	// Convert to sorted slice
-	pattern := make(RemindPattern, 0, len(selectedDays))
-	for day := 1; day <= 200; day++ {
-		if selectedDays[day] {
-			pattern = append(pattern, day)
-		}
+	// Update in-memory pattern
+	if err := a.sheetService.UpdatePattern(name, pattern); err != nil {
+		return err
 	}
 
 	// Save to JSON file
-	patternFile := "pattern.json"
-	if err := SavePatternToJSON(patternFile, pattern); err != nil {
-		return fmt.Errorf("failed to save pattern: %v", err)
+	if err := a.savePatterns(); err != nil {
+		return err
 	}
 
-	// Update in-memory pattern
-	a.sheetService.UpdatePattern(pattern)
-
 	// Redirect to /change-pattern
-	http.Redirect(vr.ResponseWriter(), vr.Request(), "/change-pattern", http.StatusSeeOther)
+	http.Redirect(vr.ResponseWriter(), vr.Request(), "/change-pattern?name="+url.QueryEscape(name), http.StatusSeeOther)
 	return nil
 }
 
-// HandleSearch handles GET /search - searches sheets using regex pattern from q query parameter
-func (a *App) HandleSearch(vr *views.ViewRenderer) error {
+// HandleCreatePattern handles POST /patterns - creates a named pattern from comma separated intervals
+func (a *App) HandleCreatePattern(vr *views.ViewRenderer) error {
+	r := vr.Request()
+	name := strings.TrimSpace(r.FormValue("name"))
+	pattern, err := ParseRemindPattern(r.FormValue("pattern"))
+	if err != nil {
+		return err
+	}
+
+	if err := a.sheetService.CreatePattern(name, pattern); err != nil {
+		return err
+	}
+	if err := a.savePatterns(); err != nil {
+		return err
+	}
+
+	http.Redirect(vr.ResponseWriter(), r, "/change-pattern?name="+url.QueryEscape(name), http.StatusSeeOther)
+	return nil
+}
+
+// HandleRenamePattern handles POST /patterns/{name}/rename - renames a pattern, keeping the sheets following it
+func (a *App) HandleRenamePattern(vr *views.ViewRenderer) error {
+	r := vr.Request()
+	name := r.PathValue("name")
+	newName := strings.TrimSpace(r.FormValue("new_name"))
+
+	if err := a.sheetService.RenamePattern(name, newName); err != nil {
+		return err
+	}
+	if err := a.savePatterns(); err != nil {
+		return err
+	}
+
+	http.Redirect(vr.ResponseWriter(), r, "/change-pattern?name="+url.QueryEscape(newName), http.StatusSeeOther)
+	return nil
+}
+
+// HandleDeletePattern handles POST /patterns/{name}/delete - deletes a pattern, its sheets go back to the default pattern
+func (a *App) HandleDeletePattern(vr *views.ViewRenderer) error {
 	r := vr.Request()
-	query := r.URL.Query().Get("q")
+	name := r.PathValue("name")
 
-	// Search for matching memory sheets
-	memoryResults, err := a.sheetService.Search(query)
+	if err := a.sheetService.DeletePattern(name); err != nil {
+		return err
+	}
+	if err := a.savePatterns(); err != nil {
+		return err
+	}
+
+	http.Redirect(vr.ResponseWriter(), r, "/change-pattern", http.StatusSeeOther)
+	return nil
+}
+
+// ShowTrash handles GET /trash - lists the deleted sheets and nav sheets
+func (a *App) ShowTrash(vr *views.ViewRenderer) error {
+	items, err := a.trash.List()
 	if err != nil {
 		return err
 	}
+	return vr.ShowTrash(items, int(a.trash.Retention()/(24*time.Hour)))
+}
+
+// HandleRestoreTrash handles POST /trash/restore - puts the deleted document of the key form value back in place
+func (a *App) HandleRestoreTrash(vr *views.ViewRenderer) error {
+	r := vr.Request()
+	key, err := a.trash.Restore(r.FormValue("key"))
+	if err != nil {
+		return err
+	}
+	// the watcher would catch up too, but the sheet should be back by the time the page loads
+	if err := a.reload(key); err != nil {
+		return err
+	}
 
-	// Search for matching nav sheets
-	navResults, err := a.navSheetService.Search(query)
+	http.Redirect(vr.ResponseWriter(), r, "/trash", http.StatusSeeOther)
+	return nil
+}
+
+// HandlePurgeTrash handles POST /trash/purge - deletes the document of the key form value for good
+func (a *App) HandlePurgeTrash(vr *views.ViewRenderer) error {
+	r := vr.Request()
+	if err := a.purgeTrash(r.FormValue("key")); err != nil {
+		return err
+	}
+
+	http.Redirect(vr.ResponseWriter(), r, "/trash", http.StatusSeeOther)
+	return nil
+}
+
+// HandleEmptyTrash handles POST /trash/empty - deletes every document in the trash for good
+func (a *App) HandleEmptyTrash(vr *views.ViewRenderer) error {
+	r := vr.Request()
+	items, err := a.trash.List()
 	if err != nil {
 		return err
 	}
+	for _, item := range items {
+		if err := a.purgeTrash(item.Key); err != nil {
+			return err
+		}
+	}
 
-	return vr.SearchResults(memoryResults, navResults)
+	http.Redirect(vr.ResponseWriter(), r, "/trash", http.StatusSeeOther)
+	return nil
+}
+
+// savePatterns writes the in-memory patterns to the configured pattern file, or to the store without one
+func (a *App) savePatterns() error {
+	var err error
+	if a.patternFile != "" {
+		err = SavePatternsToJSON(a.patternFile, a.sheetService.GetPatterns())
+	} else {
+		err = SavePatternsToStore(a.store, a.sheetService.GetPatterns())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save patterns: %v", err)
+	}
+	return nil
+}
+
+// ShowSheetPattern handles GET /sheets/{id}/pattern - returns the form to pick the pattern of a sheet
+func (a *App) ShowSheetPattern(vr *views.ViewRenderer) error {
+	r := vr.Request()
+	sheet, err := a.sheetService.GetSheetByID(r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+	return vr.ShowSheetPattern(sheet, a.sheetService.GetPatterns().Names())
+}
+
+// HandleSetSheetPattern handles PUT /sheets/{id}/pattern - makes a sheet follow another pattern
+func (a *App) HandleSetSheetPattern(vr *views.ViewRenderer) error {
+	r := vr.Request()
+	id := r.PathValue("id")
+
+	err := a.sheetService.SetSheetPattern(id, r.FormValue("name"))
+	if err != nil {
+		return err
+	}
+
+	sheet, err := a.sheetService.GetSheetByID(id)
+	if err != nil {
+		return err
+	}
+	return vr.SheetComponent(sheet)
+}
+
+// HandleSnoozeSheet handles POST /sheets/{id}/snooze - puts off the next review of a sheet by the days form value
+func (a *App) HandleSnoozeSheet(vr *views.ViewRenderer) error {
+	r := vr.Request()
+	id := r.PathValue("id")
+
+	days, err := strconv.Atoi(r.FormValue("days"))
+	if err != nil {
+		return errors.New("invalid number of days")
+	}
+	if err := a.sheetService.SnoozeSheet(id, days); err != nil {
+		return err
+	}
+
+	sheet, err := a.sheetService.GetSheetByID(id)
+	if err != nil {
+		return err
+	}
+	return vr.SheetComponent(sheet)
+}
+
+// HandleArchiveSheet handles POST /sheets/{id}/archive - retires a sheet from the reviews
+func (a *App) HandleArchiveSheet(vr *views.ViewRenderer) error {
+	return a.setArchived(vr, true)
+}
+
+// HandleUnarchiveSheet handles POST /sheets/{id}/unarchive - brings an archived sheet back to the reviews
+func (a *App) HandleUnarchiveSheet(vr *views.ViewRenderer) error {
+	return a.setArchived(vr, false)
+}
+
+func (a *App) setArchived(vr *views.ViewRenderer, archived bool) error {
+	r := vr.Request()
+	id := r.PathValue("id")
+
+	err := a.sheetService.SetArchived(id, archived)
+	if err != nil {
+		return err
+	}
+
+	sheet, err := a.sheetService.GetSheetByID(id)
+	if err != nil {
+		return err
+	}
+	return vr.SheetComponent(sheet)
+}
+
+// ShowArchive handles GET /archive - lists the archived sheets
+func (a *App) ShowArchive(vr *views.ViewRenderer) error {
+	return vr.ShowArchive(a.sheetService.ArchivedSheets())
+}
+
+// searchPageSize is how many search results are shown at a time, scrolling to the end loads the next ones
+const searchPageSize = 20
+
+// maxSearchPage is the last page a search can have
+const maxSearchPage = maxSearchResults/searchPageSize + 1
+
+// HandleSearch handles GET /search - searches sheets and nav sheets with the query language of the q query parameter
+// the results are snippets, a page at a time picked by the page query parameter; an empty query shows today's sheets
+func (a *App) HandleSearch(vr *views.ViewRenderer) error {
+	r := vr.Request()
+	q := r.URL.Query().Get("q")
+	if strings.TrimSpace(q) == "" {
+		remindingSheets, err := a.sheetService.LookUpSheets(Today())
+		if err != nil {
+			return err
+		}
+		return vr.SheetListingComponent(remindingSheets)
+	}
+	query, err := parseSearchQuery(q)
+	if err != nil {
+		return err
+	}
+	// the pages past the most results a search returns are empty anyway, and a huge page would overflow the offset
+	page := 1
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		page, err = strconv.Atoi(pageStr)
+		if err != nil || page < 1 || page > maxSearchPage {
+			return fmt.Errorf("page must be a number from 1 to %d", maxSearchPage)
+		}
+	}
+
+	// one result past the limit tells if there were more; an abandoned request stops the search by its context
+	ctx := r.Context()
+	memoryResults, err := a.sheetService.Search(ctx, query, maxSearchResults+1)
+	if err != nil {
+		return err
+	}
+	navResults, err := a.navSheetService.Search(ctx, query, maxSearchResults+1)
+	if err != nil {
+		return err
+	}
+
+	// the sheets and the nav sheets are ranked together, so a near match of either comes after the exact ones of both
+	results := append(memoryResults, navResults...)
+	sortSearchResults(results)
+	limited := len(results) > maxSearchResults
+	if limited {
+		results = results[:maxSearchResults]
+	}
+
+	total := len(results)
+	start := max(min((page-1)*searchPageSize, total), 0)
+	end := min(start+searchPageSize, total)
+	pageResults := make([]models.SearchResult, 0, end-start)
+	for _, result := range results[start:end] {
+		pageResults = append(pageResults, result.SearchResult)
+	}
+
+	nextURL := ""
+	if end < total {
+		nextURL = fmt.Sprintf("/search?q=%s&page=%d", url.QueryEscape(q), page+1)
+	}
+	return vr.SearchHits(pageResults, q, total, limited, page == 1, nextURL)
+}
+
+// searchHighlighter returns the highlighter of the q query parameter, which the search results pass on
+// to mark the matches in the sheet they expand to
+func searchHighlighter(r *http.Request) models.Highlighter {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		return nil
+	}
+	query, err := parseSearchQuery(q)
+	if err != nil {
+		return nil
+	}
+	return query.Highlighter()
+}
+
+// ShowTags handles GET /tags - lists every tag of the memory sheets and nav sheets
+func (a *App) ShowTags(vr *views.ViewRenderer) error {
+	counts := make(map[string]int)
+	a.sheetService.CountTags(counts)
+	a.navSheetService.CountTags(counts)
+
+	tags := make([]models.TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tags = append(tags, models.TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Tag < tags[j].Tag
+	})
+	return vr.ShowTags(tags)
+}
+
+// ShowTag handles GET /tags/{tag} - lists the memory sheets and nav sheets carrying a tag
+func (a *App) ShowTag(vr *views.ViewRenderer) error {
+	tag := models.NormalizeTag(vr.Request().PathValue("tag"))
+	if tag == "" {
+		return errors.New("tag cannot be empty")
+	}
+	return vr.ShowTag(tag, a.sheetService.SheetsByTag(tag), a.navSheetService.SheetsByTag(tag))
 }
 
 // ShowNavSheet handles GET /nav-sheets/{title} - returns a specific nav sheet
+// the optional q query parameter marks the matches of a search in it
 func (a *App) ShowNavSheet(vr *views.ViewRenderer) error {
 	r := vr.Request()
 	title := r.PathValue("title")
@@ -237,7 +671,9 @@ func (a *App) ShowNavSheet(vr *views.ViewRenderer) error {
 	if err != nil {
 		return err
 	}
-	return vr.NavSheetComponent(sheet)
+	highlightedSheet := *sheet
+	highlightedSheet.Highlight = searchHighlighter(r)
+	return vr.NavSheetComponent(&highlightedSheet)
 }
 
 // ShowEditNavSheet handles GET /nav-sheets/{title}/edit - returns the edit page for a nav sheet
@@ -261,6 +697,7 @@ func (a *App) ShowCreateNavSheet(vr *views.ViewRenderer) error {
 }
 
 // HandleUpdateNavSheet handles PUT /nav-sheets/{title} - updates an existing nav sheet
+// an edit made on an outdated version gets the conflict form instead, with both versions and a merge of them
 func (a *App) HandleUpdateNavSheet(vr *views.ViewRenderer) error {
 	r := vr.Request()
 	title := r.PathValue("title")
@@ -272,7 +709,11 @@ func (a *App) HandleUpdateNavSheet(vr *views.ViewRenderer) error {
 	content := r.FormValue("content")
 
 	// Update the sheet
-	err := a.navSheetService.Update(title, content)
+	err := a.navSheetService.Update(title, content, r.FormValue("version"))
+	var conflict *EditConflict
+	if errors.As(err, &conflict) {
+		return vr.ShowNavSheetConflict(title, conflict.Saved, content, mergeVersions(conflict.Saved, content))
+	}
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,284 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linn221/memory-sheets/views"
+)
+
+// newTestServer runs the app on the store and returns it with a function serving a request to its routes
+// a form is sent url encoded, handlers answer an error with the error box and HX-Reswap: none
+func newTestServer(t *testing.T, store Store) (*App, func(method string, path string, form url.Values) *httptest.ResponseRecorder) {
+	t.Helper()
+	a := NewAppWithStore(store, "")
+	mux := http.NewServeMux()
+	a.SetupRoutes(mux)
+	return a, func(method string, path string, form url.Values) *httptest.ResponseRecorder {
+		var req *http.Request
+		if form != nil {
+			req = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest(method, path, nil)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+}
+
+// failed tells if the handler answered with the error box
+func failed(rec *httptest.ResponseRecorder) bool {
+	return rec.Header().Get("HX-Reswap") == "none"
+}
+
+func TestNavSheetRoutes(t *testing.T) {
+	a, do := newTestServer(t, NewMemoryStore())
+
+	rec := do("POST", "/nav-sheets", url.Values{"title": {"go/basics"}, "content": {"text"}})
+	if !failed(rec) || !strings.Contains(rec.Body.String(), "cannot have slashes") {
+		t.Errorf("POST /nav-sheets of a title with a slash = %d %q, want the error box", rec.Code, rec.Body.String())
+	}
+	if sheets := a.navSheetService.ListSheets(); len(sheets) != 0 {
+		t.Fatalf("nav sheets after the rejected title = %d, want none", len(sheets))
+	}
+
+	requests := []struct {
+		method string
+		path   string
+		form   url.Values
+		want   string
+	}{
+		{"POST", "/nav-sheets", url.Values{"title": {"go-basics"}, "content": {"first text"}}, "first text"},
+		{"GET", "/nav-sheets/go-basics", nil, "first text"},
+		{"GET", "/nav-sheets/go-basics/edit", nil, "first text"},
+		{"PUT", "/nav-sheets/go-basics", url.Values{"content": {"edited text"}}, "edited text"},
+		{"DELETE", "/nav-sheets/go-basics", nil, ""},
+	}
+	for _, req := range requests {
+		rec := do(req.method, req.path, req.form)
+		if rec.Code != http.StatusOK || failed(rec) || !strings.Contains(rec.Body.String(), req.want) {
+			t.Errorf("%s %s = %d %q, want %q", req.method, req.path, rec.Code, rec.Body.String(), req.want)
+		}
+	}
+	if sheets := a.navSheetService.ListSheets(); len(sheets) != 0 {
+		t.Errorf("nav sheets after the delete = %d, want none", len(sheets))
+	}
+}
+
+// failingStore is a store whose writes of one document fail
+type failingStore struct {
+	Store
+	key string
+}
+
+func (s *failingStore) Put(key string, data []byte) error {
+	if key == s.key {
+		return errors.New("disk full")
+	}
+	return s.Store.Put(key, data)
+}
+
+func TestReviewIsKeptWhenTheLogCannotBeWritten(t *testing.T) {
+	a, do := newTestServer(t, &failingStore{Store: NewMemoryStore(), key: reviewLogKey})
+	sheet, err := a.sheetService.CreateSheet(Today(), "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := do("POST", sheet.Url()+"/review", url.Values{"grade": {"good"}})
+	if failed(rec) {
+		t.Errorf("the review answered with an error: %s", rec.Body.String())
+	}
+	if graded, err := a.sheetService.GetSheetByID(sheet.ID()); err != nil || !graded.Review.IsGraded() {
+		t.Errorf("the grade of the review is not saved: %v", err)
+	}
+}
+
+func TestCreateSheetDates(t *testing.T) {
+	tests := []struct {
+		name    string
+		date    string
+		wantErr string
+	}{
+		{"today", "", ""},
+		{"backfilled", "2001-05-04", ""},
+		{"first day of the floor", "2000-01-01", ""},
+		{"before the floor", "1999-12-31", "sheets start from the year 2000"},
+		{"year one", "0001-01-01", "sheets start from the year 2000"},
+		{"future", Today().AddDate(0, 0, 1).Format(time.DateOnly), "in the future"},
+		{"malformed", "2001-13-01", "invalid date format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, do := newTestServer(t, NewMemoryStore())
+			rec := do("POST", "/sheets", url.Values{"content": {"text"}, "date": {tt.date}})
+			if tt.wantErr == "" {
+				if failed(rec) {
+					t.Fatalf("POST /sheets of %q = %q, want a sheet", tt.date, rec.Body.String())
+				}
+				if sheets := a.sheetService.AllSheets(); len(sheets) != 1 {
+					t.Errorf("sheets = %d, want 1", len(sheets))
+				}
+				return
+			}
+			if !failed(rec) || !strings.Contains(rec.Body.String(), tt.wantErr) {
+				t.Errorf("POST /sheets of %q = %q, want the error box with %q", tt.date, rec.Body.String(), tt.wantErr)
+			}
+			if sheets := a.sheetService.AllSheets(); len(sheets) != 0 {
+				t.Errorf("sheets after the rejected date = %d, want none", len(sheets))
+			}
+		})
+	}
+}
+
+func TestChangePattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		wantErr string
+	}{
+		{"default", "1, 1, 2, 3", ""},
+		{"light", "2,4 , 8,", ""},
+		{"default", "", "pattern cannot be empty"},
+		{"default", " , ", "pattern cannot be empty"},
+		{"default", "1, two", `invalid interval "two"`},
+		{"default", "1, 1.5", `invalid interval "1.5"`},
+		{"default", "1, 3, 2", "cannot get shorter, 2 comes after 3"},
+		{"default", "-1, 2", "cannot be negative"},
+		{"default", "0, 0", "cannot end in 0"},
+		{"unknown", "1, 2", `pattern "unknown" does not exist`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.pattern, func(t *testing.T) {
+			a, do := newTestServer(t, NewMemoryStore())
+			before := a.sheetService.GetPatterns()
+			rec := do("POST", "/change-pattern", url.Values{"name": {tt.name}, "pattern": {tt.pattern}})
+			if tt.wantErr == "" {
+				if failed(rec) || rec.Code != http.StatusSeeOther {
+					t.Fatalf("POST /change-pattern = %d %q, want a redirect", rec.Code, rec.Body.String())
+				}
+				want, _ := ParseRemindPattern(tt.pattern)
+				if got := a.sheetService.GetPatterns()[tt.name]; !reflect.DeepEqual(got, want) {
+					t.Errorf("saved pattern = %v, want %v", got, want)
+				}
+				return
+			}
+			if !failed(rec) || !strings.Contains(rec.Body.String(), html.EscapeString(tt.wantErr)) {
+				t.Errorf("POST /change-pattern = %q, want the error box with %q", rec.Body.String(), tt.wantErr)
+			}
+			if got := a.sheetService.GetPatterns(); !reflect.DeepEqual(got, before) {
+				t.Errorf("patterns after the rejected change = %v, want %v", got, before)
+			}
+		})
+	}
+}
+
+func TestForecastPreview(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr string
+	}{
+		{"", "Review Forecast", ""},
+		{"?days=7&name=light&pattern=1,2", "Previewing <code>light</code> as <code>1, 2</code>", ""},
+		{"?days=0", "", "days must be a number from 1 to 366"},
+		{"?days=367", "", "days must be a number from 1 to 366"},
+		{"?pattern=3,1", "", "cannot get shorter"},
+		{"?name=unknown&pattern=1,2", "", "does not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, do := newTestServer(t, NewMemoryStore())
+			rec := do("GET", "/forecast"+tt.query, nil)
+			if tt.wantErr != "" {
+				if !failed(rec) || !strings.Contains(rec.Body.String(), tt.wantErr) {
+					t.Errorf("GET /forecast%s = %q, want the error box with %q", tt.query, rec.Body.String(), tt.wantErr)
+				}
+				return
+			}
+			if failed(rec) || !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("GET /forecast%s = %q, want %q", tt.query, rec.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchPages(t *testing.T) {
+	tests := []struct {
+		name     string
+		sheets   int
+		page     int
+		want     int
+		wantNext bool
+		count    string
+	}{
+		{"first page", 45, 1, searchPageSize, true, "45 results"},
+		{"middle page", 45, 2, searchPageSize, true, ""},
+		{"last page", 45, 3, 5, false, ""},
+		{"past the last page", 45, 4, 0, false, ""},
+		{"a single result", 1, 1, 1, false, "1 result"},
+		{"limited", maxSearchResults + 1, 1, searchPageSize, true, fmt.Sprintf("The first %d results", maxSearchResults)},
+		{"last page of a limited search", maxSearchResults + 1, maxSearchResults / searchPageSize, searchPageSize, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, do := newTestServer(t, NewMemoryStore())
+			for i := range tt.sheets {
+				if _, err := a.sheetService.CreateSheet(Today().AddDate(0, 0, -i), fmt.Sprintf("note %d", i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rec := do("GET", fmt.Sprintf("/search?q=note&page=%d", tt.page), nil)
+			body := rec.Body.String()
+			if failed(rec) {
+				t.Fatalf("GET /search = %q", body)
+			}
+			if got := strings.Count(body, `id="snippet-`); got != tt.want {
+				t.Errorf("results on page %d = %d, want %d", tt.page, got, tt.want)
+			}
+			next := fmt.Sprintf(`hx-get="/search?q=note&amp;page=%d"`, tt.page+1)
+			if strings.Contains(body, next) != tt.wantNext {
+				t.Errorf("page %d links the next page %v, want %v", tt.page, !tt.wantNext, tt.wantNext)
+			}
+			if tt.count != "" && !strings.Contains(body, tt.count) {
+				t.Errorf("page %d = %q, want the count %q", tt.page, body, tt.count)
+			}
+		})
+	}
+
+	_, do := newTestServer(t, NewMemoryStore())
+	for _, page := range []string{"0", "x", strconv.Itoa(maxSearchPage + 1)} {
+		if rec := do("GET", "/search?q=note&page="+page, nil); !failed(rec) {
+			t.Errorf("GET /search of page %s = %d, want the error box", page, rec.Code)
+		}
+	}
+}
+
+func TestSearchOfACancelledRequest(t *testing.T) {
+	a, _ := newTestServer(t, NewMemoryStore())
+	if _, err := a.sheetService.CreateSheet(Today(), "note"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/search?q=note", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	views.Handler(a.HandleSearch)(rec, req)
+	// the client is gone, only the search stopping matters
+	if !failed(rec) || strings.Contains(rec.Body.String(), "snippet-") {
+		t.Errorf("search of a cancelled request = %q, want it stopped without results", rec.Body.String())
+	}
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the documents in memory, for running the services without touching disk
+type MemoryStore struct {
+	mu       sync.Mutex
+	docs     map[string]memoryDoc
+	watchers storeWatchers
+}
+
+type memoryDoc struct {
+	data    []byte
+	modTime time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{docs: make(map[string]memoryDoc)}
+}
+
+func (s *MemoryStore) List(prefix string) ([]StoreItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix = strings.TrimSuffix(prefix, "/")
+	var items []StoreItem
+	for key, doc := range s.docs {
+		if prefix == "" || strings.HasPrefix(key, prefix+"/") {
+			items = append(items, StoreItem{Key: key, ModTime: doc.modTime})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Key < items[j].Key
+	})
+	return items, nil
+}
+
+func (s *MemoryStore) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.docs[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, fs.ErrNotExist)
+	}
+	return append([]byte(nil), doc.data...), nil
+}
+
+func (s *MemoryStore) Put(key string, data []byte) error {
+	if key == "" {
+		return fmt.Errorf("invalid key: %s", key)
+	}
+
+	s.mu.Lock()
+	s.docs[key] = memoryDoc{data: append([]byte(nil), data...), modTime: time.Now()}
+	s.mu.Unlock()
+
+	s.watchers.notify(StoreEvent{Key: key, Op: StorePut})
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	_, ok := s.docs[key]
+	delete(s.docs, key)
+	s.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%s: %w", key, fs.ErrNotExist)
+	}
+	s.watchers.notify(StoreEvent{Key: key, Op: StoreDelete})
+	return nil
+}
+
+func (s *MemoryStore) Watch(ctx context.Context) (<-chan StoreEvent, error) {
+	return s.watchers.add(ctx), nil
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// MigrateToStore copies the sheets directory, with its nav sheets, state and review log, and the pattern file into dst
+// it is meant to run once when moving to another store such as SQLite, documents already in dst are replaced
+func MigrateToStore(dst Store, dir string, patternFile string) (int, error) {
+	n, err := CopyStore(dst, NewFileStore(dir))
+	if err != nil {
+		return n, err
+	}
+
+	data, err := os.ReadFile(patternFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return n, nil
+		}
+		return n, err
+	}
+	if _, err := parsePatterns(patternFile, data); err != nil {
+		return n, err
+	}
+	if err := dst.Put(patternKey, data); err != nil {
+		return n, err
+	}
+	return n + 1, nil
+}
+
+// ExportFromStore writes the documents of src back to the sheets directory and the pattern file
+// the result is the layout MigrateToStore reads, so the app can run on the files again
+func ExportFromStore(src Store, dir string, patternFile string) (int, error) {
+	data, err := src.Get(patternKey)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	hasPatterns := err == nil
+
+	// the patterns go to their own file, everything else to the sheets directory
+	dst := NewFileStore(dir)
+	items, err := src.List("")
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, item := range items {
+		if item.Key == patternKey {
+			continue
+		}
+		doc, err := src.Get(item.Key)
+		if err != nil {
+			return n, err
+		}
+		if err := dst.Put(item.Key, doc); err != nil {
+			return n, fmt.Errorf("failed to export %s: %v", item.Key, err)
+		}
+		n++
+	}
+
+	if hasPatterns {
+		if err := os.MkdirAll(filepath.Dir(patternFile), 0755); err != nil {
+			return n, err
+		}
+		if err := writeFileAtomic(patternFile, data, 0644); err != nil {
+			return n, fmt.Errorf("failed to write pattern file: %v", err)
+		}
+		n++
+	}
+	return n, nil
+}
@@ -1,148 +1,173 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io/fs"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"sync"
 
 	"github.com/linn221/memory-sheets/models"
 )
 
+// navPrefix is the directory of the store that keeps the nav sheets
+const navPrefix = "nav"
+
 type NavSheetService struct {
 	mu     sync.Mutex
-	dir    string
+	store  Store
 	sheets []*models.NavSheet
+	// index is the full text index of the nav sheets by title, for ranked search
+	// it is used on every store, the titles are not in the documents a store indexes
+	index *searchIndex
 }
 
-// ReadDir reads the nav directory and scans markdown files, storing them in NavSheetService
-// The filename without extension becomes the Title of NavSheet
+// ReadDir reads the nav directory of the store and scans markdown documents, storing them in NavSheetService
+// The key without the nav directory and extension becomes the Title of NavSheet
 func (s *NavSheetService) ReadDir() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.sheets = []*models.NavSheet{}
+	s.index = newSearchIndex()
 
-	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	items, err := s.store.List(navPrefix)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		// Only process .md documents
+		if !strings.HasSuffix(item.Key, ".md") {
+			continue
 		}
-
-		// Only process .md files
-		if !info.IsDir() && strings.HasSuffix(path, ".md") {
-			sheet, err := parseFilepathToNavSheet(s.dir, path)
-			if err != nil {
-				fmt.Printf("%s file does not get parsed for some reason: %v\n", path, err)
-			} else {
-				s.sheets = append(s.sheets, sheet)
-			}
+		sheet, err := parseKeyToNavSheet(s.store, item.Key)
+		if err != nil {
+			fmt.Printf("%s file does not get parsed for some reason: %v\n", item.Key, err)
+		} else {
+			s.sheets = append(s.sheets, sheet)
+			s.indexSheet(sheet)
 		}
+	}
 
-		return nil
-	})
-
-	return err
+	return nil
 }
 
 // Create creates a new NavSheet with the given title and text
-// Writes to file in nav directory and updates in-memory sheets
+// Writes to the nav directory of the store and updates in-memory sheets
 func (s *NavSheetService) Create(title string, text string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	filePath := s.fromTitleToFilepath(title)
+	key, err := s.fromTitleToKey(title)
+	if err != nil {
+		return err
+	}
 
-	// Check if file exists
-	if fileExists(filePath) {
+	// Check if the document exists
+	exists, err := storeHas(s.store, key)
+	if err != nil {
+		return err
+	}
+	if exists {
 		return fmt.Errorf("nav sheet already exists with title %s", title)
 	}
 
-	// Ensure directory exists (including subdirectories if title contains path separators)
-	dir := filepath.Dir(filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %v", err)
+	// A broken front matter would be lost on the next read, reject it up front
+	meta, err := models.ParseFrontMatter(text)
+	if err != nil {
+		return err
 	}
 
-	// Write the file
-	if err := writeFileContent(filePath, text); err != nil {
+	// Write the document
+	if err := s.store.Put(key, []byte(text)); err != nil {
 		return err
 	}
 
 	// Update in-memory sheet
-	for _, sheet := range s.sheets {
-		if sheet.Title == title {
-			sheet.Text = text
-			return nil
-		}
-	}
-
-	// If not found in memory, add it
-	sheet := &models.NavSheet{
+	s.putSheet(&models.NavSheet{
 		Title: title,
 		Text:  text,
-	}
-	s.sheets = append(s.sheets, sheet)
+		Meta:  meta,
+	})
 
 	return nil
 }
 
 // Update updates an existing NavSheet with the given title and text
-// Writes to file in nav directory and updates in-memory sheets
-func (s *NavSheetService) Update(title string, text string) error {
+// Writes to the nav directory of the store and updates in-memory sheets
+// an edit made on a version that was changed in the meantime gets an EditConflict
+func (s *NavSheetService) Update(title string, text string, version string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	filePath := s.fromTitleToFilepath(title)
+	key, err := s.fromTitleToKey(title)
+	if err != nil {
+		return err
+	}
 
-	// Check if file exists
-	if !fileExists(filePath) {
+	// Check if the document exists
+	exists, err := storeHas(s.store, key)
+	if err != nil {
+		return err
+	}
+	if !exists {
 		return fmt.Errorf("nav sheet does not exist with title %s", title)
 	}
 
-	// Write the file
-	if err := writeFileContent(filePath, text); err != nil {
+	if err := checkVersion(s.store, key, version, text); err != nil {
 		return err
 	}
 
-	// Update in-memory sheet
-	for _, sheet := range s.sheets {
-		if sheet.Title == title {
-			sheet.Text = text
-			return nil
-		}
+	// A broken front matter would be lost on the next read, reject it up front
+	meta, err := models.ParseFrontMatter(text)
+	if err != nil {
+		return err
 	}
 
-	// If not found in memory, add it
-	sheet := &models.NavSheet{
+	// Write the document
+	if err := s.store.Put(key, []byte(text)); err != nil {
+		return err
+	}
+
+	// Update in-memory sheet
+	s.putSheet(&models.NavSheet{
 		Title: title,
 		Text:  text,
-	}
-	s.sheets = append(s.sheets, sheet)
+		Meta:  meta,
+	})
 
 	return nil
 }
 
 // Delete deletes a NavSheet with the given title
-// Deletes the file and removes from in-memory sheets
+// Moves the document to the trash and removes from in-memory sheets
 func (s *NavSheetService) Delete(title string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	filePath := s.fromTitleToFilepath(title)
+	key, err := s.fromTitleToKey(title)
+	if err != nil {
+		return err
+	}
 
-	// Check if file exists
-	if !fileExists(filePath) {
+	// Check if the document exists
+	exists, err := storeHas(s.store, key)
+	if err != nil {
+		return err
+	}
+	if !exists {
 		return fmt.Errorf("nav sheet does not exist with title %s", title)
 	}
 
-	// Delete the file
-	if err := deleteFile(filePath); err != nil {
+	// Move the document to the trash
+	if err := moveToTrash(s.store, key); err != nil {
 		return err
 	}
 
 	// Remove from in-memory sheets
+	s.index.Delete(title)
 	for i, sheet := range s.sheets {
 		if sheet.Title == title {
 			s.sheets = append(s.sheets[:i], s.sheets[i+1:]...)
@@ -154,7 +179,7 @@ func (s *NavSheetService) Delete(title string) error {
 }
 
 // Get retrieves a NavSheet by title
-// Returns the sheet from memory or loads from file if not in memory
+// Returns the sheet from memory or loads from the store if not in memory
 func (s *NavSheetService) Get(title string) (*models.NavSheet, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -166,15 +191,23 @@ func (s *NavSheetService) Get(title string) (*models.NavSheet, error) {
 		}
 	}
 
-	// If not in memory, try to load from file
-	filePath := s.fromTitleToFilepath(title)
-	if fileExists(filePath) {
-		sheet, err := parseFilepathToNavSheet(s.dir, filePath)
+	// If not in memory, try to load from the store
+	key, err := s.fromTitleToKey(title)
+	if err != nil {
+		return nil, err
+	}
+	exists, err := storeHas(s.store, key)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		sheet, err := parseKeyToNavSheet(s.store, key)
 		if err != nil {
 			return nil, err
 		}
 		// Add to in-memory sheets
 		s.sheets = append(s.sheets, sheet)
+		s.indexSheet(sheet)
 		return sheet, nil
 	}
 
@@ -192,73 +225,200 @@ func (s *NavSheetService) ListSheets() []*models.NavSheet {
 	return sheets
 }
 
-// Search searches through all nav sheets using the provided regex pattern
-// Returns matching sheets with Text field modified to bold matched strings using markdown (**text**)
-func (s *NavSheetService) Search(patternStr string) ([]*models.NavSheet, error) {
+// SheetsByTag returns the nav sheets carrying the tag
+func (s *NavSheetService) SheetsByTag(tag string) []*models.NavSheet {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if patternStr == "" {
-		return s.sheets, nil
+	var sheets []*models.NavSheet
+	for _, sheet := range s.sheets {
+		if sheet.HasTag(tag) {
+			sheets = append(sheets, sheet)
+		}
 	}
+	return sheets
+}
 
-	// Compile regex pattern with case-insensitive flag
-	re, err := regexp.Compile("(?i)" + patternStr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid regex pattern: %v", err)
-	}
+// CountTags adds the number of nav sheets carrying each tag to counts
+func (s *NavSheetService) CountTags(counts map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	var matchingSheets []*models.NavSheet
 	for _, sheet := range s.sheets {
-		// Check if the pattern matches the text or title
-		if re.MatchString(sheet.Text) || re.MatchString(sheet.Title) {
-			// Create a copy of the sheet with highlighted matches
-			highlightedText := re.ReplaceAllStringFunc(sheet.Text, func(match string) string {
-				return "**" + match + "**"
-			})
-			highlightedTitle := re.ReplaceAllStringFunc(sheet.Title, func(match string) string {
-				return "**" + match + "**"
-			})
-
-			// Create a new sheet with highlighted text
-			highlightedSheet := &models.NavSheet{
-				Title: highlightedTitle,
-				Text:  highlightedText,
+		for _, tag := range sheet.Tags() {
+			counts[tag]++
+		}
+	}
+}
+
+// Search returns up to limit nav sheets matching the query by their title or text, the most relevant first
+// nav sheets have no date, so they never pass the date and due filters
+// like SheetService.Search, the sheets are scanned as copied under the lock and the scan stops once ctx is done
+// Returns copies of the matching sheets with Highlight set to mark the matched words, their titles untouched,
+// along with their hits
+func (s *NavSheetService) Search(ctx context.Context, query *searchQuery, limit int) ([]searchResult, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	text := query.Text()
+	ranked := query.Regex == nil && text != ""
+	var hits []searchHit
+	if ranked {
+		var err error
+		hits, err = s.index.Search(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	var candidates []models.NavSheet
+	var candidateHits []searchHit
+	if ranked {
+		byTitle := make(map[string]*models.NavSheet, len(s.sheets))
+		for _, sheet := range s.sheets {
+			byTitle[sheet.Title] = sheet
+		}
+		candidates = make([]models.NavSheet, 0, len(hits))
+		for _, hit := range hits {
+			if sheet, ok := byTitle[hit.ID]; ok {
+				candidates = append(candidates, *sheet)
+				candidateHits = append(candidateHits, hit)
 			}
-			matchingSheets = append(matchingSheets, highlightedSheet)
+		}
+	} else {
+		candidates = make([]models.NavSheet, 0, len(s.sheets))
+		for _, sheet := range s.sheets {
+			candidates = append(candidates, *sheet)
+			candidateHits = append(candidateHits, searchHit{ID: sheet.Title})
 		}
 	}
+	s.mu.Unlock()
 
-	return matchingSheets, nil
+	highlight := query.Highlighter()
+	var results []searchResult
+	for i := range candidates {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		sheet := &candidates[i]
+		if query.Regex != nil {
+			// Check if the pattern matches the text or title
+			if !query.Regex.MatchString(sheet.Text) && !query.Regex.MatchString(sheet.Title) {
+				continue
+			}
+		} else {
+			target := &searchTarget{
+				text:   sheet.Title + "\n" + sheet.Text,
+				hasTag: sheet.HasTag,
+				nav:    true,
+			}
+			if !query.matches(target) {
+				continue
+			}
+		}
+		sheet.Highlight = highlight
+		results = append(results, searchResult{
+			searchHit:    candidateHits[i],
+			SearchResult: models.SearchResult{NavSheet: sheet},
+		})
+		if len(results) == limit {
+			break
+		}
+	}
+	return results, nil
 }
 
-// fromTitleToFilepath converts a title to a filepath
-// The title becomes the filename with .md extension in the nav directory
-func (s *NavSheetService) fromTitleToFilepath(title string) string {
-	filename := title + ".md"
-	return filepath.Join(s.dir, filename)
-}
+// Reload brings the nav sheet of the key in line with the store after its document was changed outside the app
+// the sheet is replaced rather than changed in place, so a sheet being rendered is never seen half updated
+func (s *NavSheetService) Reload(key string) error {
+	if !strings.HasPrefix(key, navPrefix+"/") || !strings.HasSuffix(key, ".md") {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := -1
+	title := strings.TrimSuffix(strings.TrimPrefix(key, navPrefix+"/"), ".md")
+	for i, sheet := range s.sheets {
+		if sheet.Title == title {
+			index = i
+			break
+		}
+	}
 
-// parseFilepathToNavSheet parses a filepath into a NavSheet
-// The filename without extension becomes the Title, and the file content becomes the Text
-func parseFilepathToNavSheet(dir string, path string) (*models.NavSheet, error) {
-	// Get relative path from base directory
-	relPath, err := filepath.Rel(dir, path)
+	sheet, err := parseKeyToNavSheet(s.store, key)
+	if errors.Is(err, fs.ErrNotExist) {
+		if index >= 0 {
+			s.sheets = append(s.sheets[:index], s.sheets[index+1:]...)
+			s.index.Delete(title)
+		}
+		return nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get relative path: %v", err)
+		return err
 	}
 
-	// Remove .md extension to get title
-	title := strings.TrimSuffix(relPath, ".md")
+	if index < 0 {
+		s.sheets = append(s.sheets, sheet)
+		s.indexSheet(sheet)
+	} else if s.sheets[index].Text != sheet.Text {
+		s.sheets[index] = sheet
+		s.indexSheet(sheet)
+	}
+	return nil
+}
 
-	// Read file content
-	content, err := readFileContent(path)
+// putSheet puts the sheet in the place of the one of its title, or adds it when there is none, the caller must hold s.mu
+// sheets are replaced rather than changed in place, like in Reload
+func (s *NavSheetService) putSheet(sheet *models.NavSheet) {
+	s.indexSheet(sheet)
+	for i := range s.sheets {
+		if s.sheets[i].Title == sheet.Title {
+			s.sheets[i] = sheet
+			return
+		}
+	}
+	s.sheets = append(s.sheets, sheet)
+}
+
+// indexSheet puts the title and text of the sheet in the search index, the caller must hold s.mu
+func (s *NavSheetService) indexSheet(sheet *models.NavSheet) {
+	s.index.Put(sheet.Title, sheet.Title+"\n"+sheet.Text)
+}
+
+// fromTitleToKey converts a title to a store key
+// The title becomes the name of the document with .md extension in the nav directory
+func (s *NavSheetService) fromTitleToKey(title string) (string, error) {
+	// the title must stay inside the nav directory, and be a single segment of the /nav-sheets/{title} routes
+	if !filepath.IsLocal(title) || strings.ContainsAny(title, `/\`) {
+		return "", fmt.Errorf("invalid nav sheet title %s, a title cannot have slashes", title)
+	}
+	return navPrefix + "/" + title + ".md", nil
+}
+
+// parseKeyToNavSheet parses a store key into a NavSheet
+// The key without the nav directory and extension becomes the Title, and the document content becomes the Text
+func parseKeyToNavSheet(store Store, key string) (*models.NavSheet, error) {
+	// Remove the nav directory and .md extension to get title
+	title := strings.TrimSuffix(strings.TrimPrefix(key, navPrefix+"/"), ".md")
+	if strings.Contains(title, "/") {
+		return nil, fmt.Errorf("nav sheets cannot be in subdirectories of %s", navPrefix)
+	}
+
+	// Read the content
+	content, err := store.Get(key)
 	if err != nil {
 		return nil, err
 	}
 
-	return &models.NavSheet{
+	sheet := &models.NavSheet{
 		Title: title,
-		Text:  content,
-	}, nil
+	}
+	if err := sheet.SetText(string(content)); err != nil {
+		// keep the sheet readable, only its metadata is lost
+		fmt.Printf("%s front matter does not get parsed: %v\n", key, err)
+		sheet.Text = string(content)
+	}
+	return sheet, nil
 }
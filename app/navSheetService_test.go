@@ -0,0 +1,107 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+// newTestNavSheetService reads the nav sheets of the store
+func newTestNavSheetService(t *testing.T, store Store) *NavSheetService {
+	t.Helper()
+	s := &NavSheetService{store: store}
+	if err := s.ReadDir(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNavSheetTitlesStayInTheNavDirectory(t *testing.T) {
+	store := NewMemoryStore()
+	if err := store.Put("state.json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("x.md", []byte("not a nav sheet")); err != nil {
+		t.Fatal(err)
+	}
+	// a document in a subdirectory has no title the routes could take, it is not read as a nav sheet
+	if err := store.Put("nav/go/basics.md", []byte("nested")); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestNavSheetService(t, store)
+	if sheets := s.ListSheets(); len(sheets) != 0 {
+		t.Errorf("nav sheets read = %d, want none", len(sheets))
+	}
+
+	for _, title := range []string{"../x", "../state", "/x", "a/../../x", "", "go/basics", `go\basics`} {
+		_, getErr := s.Get(title)
+		errs := map[string]error{
+			"Create": s.Create(title, "text"),
+			"Update": s.Update(title, "text", ""),
+			"Get":    getErr,
+			"Delete": s.Delete(title),
+		}
+		for op, err := range errs {
+			if err == nil || !strings.Contains(err.Error(), "invalid nav sheet title") {
+				t.Errorf("%s(%q) = %v, want an invalid title error", op, title, err)
+			}
+		}
+	}
+	if data, err := store.Get("x.md"); err != nil || string(data) != "not a nav sheet" {
+		t.Errorf("x.md = %q, %v after the invalid titles", data, err)
+	}
+
+}
+
+func TestNavSheetServiceEdits(t *testing.T) {
+	store := NewMemoryStore()
+	s := newTestNavSheetService(t, store)
+
+	if err := s.Create("go-basics", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Create("go-basics", "again"); err == nil {
+		t.Error("Create of an existing title succeeded")
+	}
+	if data, err := store.Get("nav/go-basics.md"); err != nil || string(data) != "first" {
+		t.Errorf("document of the nav sheet = %q, %v", data, err)
+	}
+
+	version := models.ContentVersion("first")
+	if err := s.Update("go-basics", "edited", version); err != nil {
+		t.Fatal(err)
+	}
+	var conflict *EditConflict
+	if err := s.Update("go-basics", "edited elsewhere", version); !errors.As(err, &conflict) || conflict.Saved != "edited" {
+		t.Errorf("Update on a stale version = %v, want an edit conflict", err)
+	}
+	if sheet, err := s.Get("go-basics"); err != nil || sheet.Text != "edited" {
+		t.Errorf("Get after the edit = %v, %v", sheet, err)
+	}
+	// the sheet handed out before an edit is replaced rather than changed
+	before, err := s.Get("go-basics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update("go-basics", "edited again", ""); err != nil {
+		t.Fatal(err)
+	}
+	if before.Text != "edited" {
+		t.Errorf("text of the nav sheet handed out before the edit = %q, want it unchanged", before.Text)
+	}
+	if sheets := s.ListSheets(); len(sheets) != 1 {
+		t.Errorf("ListSheets = %d nav sheets, want 1", len(sheets))
+	}
+
+	if err := s.Delete("go-basics"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("go-basics"); err == nil {
+		t.Error("Get of a deleted nav sheet succeeded")
+	}
+	if sheets := s.ListSheets(); len(sheets) != 0 {
+		t.Errorf("ListSheets after the delete = %d nav sheets, want none", len(sheets))
+	}
+}
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+// PatternSet holds the named reminder patterns, every sheet follows one of them
+type PatternSet map[string]RemindPattern
+
+// defaultPatternSet is used when there is no pattern file yet
+func defaultPatternSet() PatternSet {
+	return PatternSet{
+		models.DefaultPatternName: {1, 1, 2, 3, 5, 8, 13, 21, 34, 55, 89},
+		"intensive":               {1, 1, 1, 2, 2, 3, 5, 8, 13, 21},
+		"light":                   {3, 7, 14, 30, 60, 120},
+	}
+}
+
+// Of returns the pattern of the name, unknown names fall back to the default pattern
+func (ps PatternSet) Of(name string) RemindPattern {
+	if pattern, ok := ps[name]; ok {
+		return pattern
+	}
+	return ps[models.DefaultPatternName]
+}
+
+// Names returns the pattern names, the default pattern first and the rest sorted
+func (ps PatternSet) Names() []string {
+	names := make([]string, 0, len(ps))
+	for name := range ps {
+		if name != models.DefaultPatternName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{models.DefaultPatternName}, names...)
+}
+
+// Clone returns a deep copy of the pattern set
+func (ps PatternSet) Clone() PatternSet {
+	clone := make(PatternSet, len(ps))
+	for name, pattern := range ps {
+		clone[name] = append(RemindPattern(nil), pattern...)
+	}
+	return clone
+}
+
+// Validate checks every pattern of the set and that the default pattern exists
+func (ps PatternSet) Validate() error {
+	if _, ok := ps[models.DefaultPatternName]; !ok {
+		return fmt.Errorf("pattern %q is missing", models.DefaultPatternName)
+	}
+	for name, pattern := range ps {
+		if err := validatePatternName(name); err != nil {
+			return err
+		}
+		if err := pattern.Validate(); err != nil {
+			return fmt.Errorf("pattern %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func validatePatternName(name string) error {
+	if name == "" {
+		return errors.New("pattern name cannot be empty")
+	}
+	if strings.TrimSpace(name) != name {
+		return errors.New("pattern name cannot start or end with spaces")
+	}
+	if len(name) > 40 {
+		return errors.New("pattern name cannot be longer than 40 characters")
+	}
+	return nil
+}
+
+// patternKey is the document of the store that keeps the patterns when the app runs without a pattern file
+const patternKey = "pattern.json"
+
+// LoadPatternsFromJSON loads the named patterns from a JSON file
+// a file holding a single pattern array, the old format, becomes the default pattern
+func LoadPatternsFromJSON(path string) (PatternSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Return default patterns if file doesn't exist
+			return defaultPatternSet(), nil
+		}
+		return nil, err
+	}
+	return parsePatterns(path, data)
+}
+
+// LoadPatternsFromStore loads the named patterns from the pattern document of the store
+func LoadPatternsFromStore(store Store) (PatternSet, error) {
+	data, err := store.Get(patternKey)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return defaultPatternSet(), nil
+		}
+		return nil, err
+	}
+	return parsePatterns(patternKey, data)
+}
+
+// parsePatterns parses the JSON of the named patterns or of a single legacy pattern read from source
+func parsePatterns(source string, data []byte) (PatternSet, error) {
+	var patterns PatternSet
+	if err := json.Unmarshal(data, &patterns); err != nil {
+		var pattern RemindPattern
+		if legacyErr := json.Unmarshal(data, &pattern); legacyErr != nil {
+			return nil, fmt.Errorf("failed to parse pattern JSON: %v", err)
+		}
+		patterns = defaultPatternSet()
+		patterns[models.DefaultPatternName] = pattern
+	}
+
+	if err := patterns.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid pattern file %s: %v", source, err)
+	}
+	return patterns, nil
+}
+
+// marshalPatterns validates the named patterns and encodes them as JSON
+func marshalPatterns(patterns PatternSet) ([]byte, error) {
+	if err := patterns.Validate(); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(patterns, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal patterns: %v", err)
+	}
+	return data, nil
+}
+
+// SavePatternsToJSON saves the named patterns to a JSON file
+func SavePatternsToJSON(path string, patterns PatternSet) error {
+	data, err := marshalPatterns(patterns)
+	if err != nil {
+		return err
+	}
+
+	// Ensure directory exists
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	// a crash mid-write must not leave a truncated pattern file behind
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write pattern file: %v", err)
+	}
+
+	return nil
+}
+
+// SavePatternsToStore saves the named patterns to the pattern document of the store
+func SavePatternsToStore(store Store, patterns PatternSet) error {
+	data, err := marshalPatterns(patterns)
+	if err != nil {
+		return err
+	}
+	return store.Put(patternKey, data)
+}
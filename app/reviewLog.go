@@ -0,0 +1,145 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+// reviewLogKey is the append-only document of the store that keeps the review history
+const reviewLogKey = "review-log.jsonl"
+
+// ReviewLog keeps the history of every review, one JSON entry per line
+// entries are only ever appended, both to the store and in memory
+type ReviewLog struct {
+	mu      sync.Mutex
+	store   Store
+	entries []models.ReviewEntry
+	// torn is set when the log ends in a line cut short, the next entry starts on a line of its own
+	torn bool
+}
+
+// NewReviewLog loads the review log of the store, a missing document is an empty log
+func NewReviewLog(store Store) (*ReviewLog, error) {
+	l := &ReviewLog{store: store}
+
+	data, err := store.Get(reviewLogKey)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return l, nil
+		}
+		return nil, err
+	}
+
+	l.torn = len(data) > 0 && data[len(data)-1] != '\n'
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry models.ReviewEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// a torn last line should not make the whole history unreadable
+			fmt.Printf("%s line %d does not get parsed: %v\n", reviewLogKey, lineNo, err)
+			continue
+		}
+		l.entries = append(l.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Append writes the entry to the end of the log and keeps it in memory
+func (l *ReviewLog) Append(entry models.ReviewEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal review entry: %v", err)
+	}
+	line := append(data, '\n')
+	if l.torn {
+		line = append([]byte{'\n'}, line...)
+	}
+
+	if appender, ok := l.store.(storeAppender); ok {
+		err = appender.Append(reviewLogKey, line)
+	} else {
+		// stores without appending rewrite the whole log
+		var log []byte
+		log, err = l.store.Get(reviewLogKey)
+		if err == nil || errors.Is(err, fs.ErrNotExist) {
+			err = l.store.Put(reviewLogKey, append(log, line...))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write review log: %v", err)
+	}
+
+	l.torn = false
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+// ForSheet returns the reviews of the sheet of the id, oldest first
+func (l *ReviewLog) ForSheet(id string) []models.ReviewEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var entries []models.ReviewEntry
+	for _, entry := range l.entries {
+		if entrySheetID(entry) == id {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// HasSheet tells if the log has any review of the sheet of the id
+func (l *ReviewLog) HasSheet(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, entry := range l.entries {
+		if entrySheetID(entry) == id {
+			return true
+		}
+	}
+	return false
+}
+
+// entrySheetID returns the ID of the sheet the entry is a review of
+// entries written before sheets had IDs only carry the date, they belong to the first sheet of that date
+func entrySheetID(entry models.ReviewEntry) string {
+	if entry.SheetID == "" {
+		return entry.SheetDate.Format(time.DateOnly)
+	}
+	return entry.SheetID
+}
+
+// Between returns the reviews that happened from (inclusive) until to (exclusive), oldest first
+func (l *ReviewLog) Between(from time.Time, to time.Time) []models.ReviewEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var entries []models.ReviewEntry
+	for _, entry := range l.entries {
+		if !entry.ReviewedAt.Before(from) && entry.ReviewedAt.Before(to) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
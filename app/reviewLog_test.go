@@ -0,0 +1,90 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+func TestReviewLog(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemoryStore() },
+		"file":   func(t *testing.T) Store { return NewFileStore(t.TempDir()) },
+	}
+	start := time.Date(2025, 12, 14, 9, 0, 0, 0, time.UTC)
+	date := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	entries := []models.ReviewEntry{
+		{SheetID: "2025-12-01", SheetDate: date, ReviewedAt: start, Outcome: models.GradeGood, TimeSpent: time.Minute},
+		{SheetID: "2025-12-01-2", SheetDate: date, ReviewedAt: start.Add(time.Hour), Outcome: models.GradeHard},
+		// entries written before sheets had IDs belong to the first sheet of their date
+		{SheetDate: date, ReviewedAt: start.Add(2 * time.Hour), Outcome: models.GradeEasy},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			log, err := NewReviewLog(store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if err := log.Append(entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// the entries are read back from the store
+			log, err = NewReviewLog(store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tests := []struct {
+				name string
+				got  []models.ReviewEntry
+				want []models.ReviewEntry
+			}{
+				{"first sheet", log.ForSheet("2025-12-01"), []models.ReviewEntry{entries[0], entries[2]}},
+				{"second sheet", log.ForSheet("2025-12-01-2"), entries[1:2]},
+				{"other sheet", log.ForSheet("2025-12-02"), nil},
+				{"first two hours", log.Between(start, start.Add(2*time.Hour)), entries[:2]},
+				{"the day", log.Between(start.Add(-9*time.Hour), start.Add(15*time.Hour)), entries},
+				{"the day before", log.Between(start.Add(-33*time.Hour), start.Add(-9*time.Hour)), nil},
+			}
+			for _, tt := range tests {
+				if fmt.Sprint(tt.got) != fmt.Sprint(tt.want) {
+					t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReviewLogAfterATornLine(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	whole := `{"sheet_id":"2025-12-01","sheet_date":"2025-12-01T00:00:00Z","reviewed_at":"2025-12-14T09:00:00Z","outcome":"good","time_spent":0}`
+	if err := store.Put(reviewLogKey, []byte(whole+"\n"+`{"sheet_id":"2025-12-0`)); err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := NewReviewLog(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := log.ForSheet("2025-12-01"); len(entries) != 1 {
+		t.Fatalf("entries read before the torn line = %d, want 1", len(entries))
+	}
+	entry := models.ReviewEntry{SheetID: "2025-12-02", ReviewedAt: time.Now().UTC(), Outcome: models.GradeEasy}
+	if err := log.Append(entry); err != nil {
+		t.Fatal(err)
+	}
+
+	// the entry appended after the torn line is not lost with it
+	log, err = NewReviewLog(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := log.ForSheet("2025-12-02"); len(entries) != 1 || entries[0].Outcome != models.GradeEasy {
+		t.Errorf("entries appended after the torn line = %v, want the easy review", entries)
+	}
+}
@@ -8,12 +8,23 @@ import (
 
 func (a *App) SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /sheets", views.Handler(a.ShowTodaySheets))
-	mux.HandleFunc("GET /sheets/{date}/edit", views.Handler(a.ShowEditSheet))
+	mux.HandleFunc("GET /sheets/{id}/edit", views.Handler(a.ShowEditSheet))
 	mux.HandleFunc("GET /all-sheets", views.Handler(a.ShowAllSheets))
-	mux.HandleFunc("GET /sheets/{date}", views.Handler(a.ShowSheet))
+	mux.HandleFunc("GET /sheets/{id}", views.Handler(a.ShowSheet))
 	mux.HandleFunc("POST /sheets", views.Handler(a.HandleCreateSheet))
-	mux.HandleFunc("PUT /sheets/{date}", views.Handler(a.HandleUpdateSheet))
-	mux.HandleFunc("DELETE /sheets/{date}", views.Handler(a.HandleDeleteSheet))
+	mux.HandleFunc("PUT /sheets/{id}", views.Handler(a.HandleUpdateSheet))
+	mux.HandleFunc("DELETE /sheets/{id}", views.Handler(a.HandleDeleteSheet))
+	mux.HandleFunc("POST /sheets/{id}/review", views.Handler(a.HandleReviewSheet))
+	mux.HandleFunc("GET /sheets/{id}/pattern", views.Handler(a.ShowSheetPattern))
+	mux.HandleFunc("PUT /sheets/{id}/pattern", views.Handler(a.HandleSetSheetPattern))
+	mux.HandleFunc("POST /sheets/{id}/snooze", views.Handler(a.HandleSnoozeSheet))
+	mux.HandleFunc("POST /sheets/{id}/archive", views.Handler(a.HandleArchiveSheet))
+	mux.HandleFunc("POST /sheets/{id}/unarchive", views.Handler(a.HandleUnarchiveSheet))
+	mux.HandleFunc("GET /sheets/{id}/history", views.Handler(a.ShowSheetHistory))
+	mux.HandleFunc("POST /sheets/{id}/history/{hash}/restore", views.Handler(a.HandleRestoreSheet))
+	mux.HandleFunc("GET /forecast", views.Handler(a.ShowForecast))
+	mux.HandleFunc("GET /tags", views.Handler(a.ShowTags))
+	mux.HandleFunc("GET /tags/{tag}", views.Handler(a.ShowTag))
 	mux.HandleFunc("GET /search", views.Handler(a.HandleSearch))
 	mux.HandleFunc("GET /nav-sheets/{title}", views.Handler(a.ShowNavSheet))
 	mux.HandleFunc("GET /nav-sheets/new", views.Handler(a.ShowCreateNavSheet))
@@ -21,6 +32,14 @@ func (a *App) SetupRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /nav-sheets/{title}/edit", views.Handler(a.ShowEditNavSheet))
 	mux.HandleFunc("PUT /nav-sheets/{title}", views.Handler(a.HandleUpdateNavSheet))
 	mux.HandleFunc("DELETE /nav-sheets/{title}", views.Handler(a.HandleDeleteNavSheet))
-	// mux.HandleFunc("GET /change-pattern", views.Handler(a.ShowChangePattern))
-	// mux.HandleFunc("POST /change-pattern", views.Handler(a.HandlePostChangePattern))
+	mux.HandleFunc("GET /archive", views.Handler(a.ShowArchive))
+	mux.HandleFunc("GET /trash", views.Handler(a.ShowTrash))
+	mux.HandleFunc("POST /trash/restore", views.Handler(a.HandleRestoreTrash))
+	mux.HandleFunc("POST /trash/purge", views.Handler(a.HandlePurgeTrash))
+	mux.HandleFunc("POST /trash/empty", views.Handler(a.HandleEmptyTrash))
+	mux.HandleFunc("GET /change-pattern", views.Handler(a.ShowChangePattern))
+	mux.HandleFunc("POST /change-pattern", views.Handler(a.HandlePostChangePattern))
+	mux.HandleFunc("POST /patterns", views.Handler(a.HandleCreatePattern))
+	mux.HandleFunc("POST /patterns/{name}/rename", views.Handler(a.HandleRenamePattern))
+	mux.HandleFunc("POST /patterns/{name}/delete", views.Handler(a.HandleDeletePattern))
 }
@@ -0,0 +1,186 @@
+package app
+
+import (
+	"math"
+	"time"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+// Scheduler decides when a sheet should be reviewed
+type Scheduler interface {
+	// IsDue reports whether the sheet has a review scheduled on date
+	IsDue(sheet *models.MemorySheet, date time.Time) bool
+	// LastDue returns the most recent scheduled review of the sheet on or before today
+	LastDue(sheet *models.MemorySheet, today time.Time) (time.Time, bool)
+	// Grade returns the new review state of the sheet after it was graded on today
+	Grade(sheet *models.MemorySheet, grade models.Grade, today time.Time) models.ReviewState
+	// Snooze returns the review state of the sheet with its next review put off by days from today
+	// the reviews after it move along, so the spacing between them is kept
+	Snooze(sheet *models.MemorySheet, days int, today time.Time) models.ReviewState
+}
+
+// newScheduler returns the scheduler used by SheetService
+// sheets that were never graded keep following their fixed pattern, overdueSince is the date missed reviews count from
+func newScheduler(patterns PatternSet, overdueSince time.Time) Scheduler {
+	return &SM2Scheduler{
+		Fallback: &PatternScheduler{Patterns: patterns, OverdueSince: overdueSince},
+	}
+}
+
+// isOutstanding tells if the review of the sheet due on due is still to be done
+// it is not when the sheet was reviewed since, or when it was missed before missed reviews counted from overdueSince
+func isOutstanding(sheet *models.MemorySheet, due time.Time, overdueSince time.Time) bool {
+	return due.After(sheet.Review.LastReviewed) && !due.Before(overdueSince)
+}
+
+// PatternScheduler reminds each sheet on the fixed ladder of intervals of its named pattern
+type PatternScheduler struct {
+	Patterns PatternSet
+	// OverdueSince is the date missed reviews count from, an earlier review is not outstanding anymore
+	OverdueSince time.Time
+}
+
+func (p *PatternScheduler) IsDue(sheet *models.MemorySheet, date time.Time) bool {
+	date = normalizeDate(date)
+	if sheet.Review.IsSnoozed(date) {
+		return false
+	}
+	return IsDateReminding(p.start(sheet), date, p.Patterns.Of(sheet.PatternName()))
+}
+
+func (p *PatternScheduler) LastDue(sheet *models.MemorySheet, today time.Time) (time.Time, bool) {
+	today = normalizeDate(today)
+	if sheet.Review.IsSnoozed(today) {
+		return time.Time{}, false
+	}
+	return LastRemindingDate(p.start(sheet), today, p.Patterns.Of(sheet.PatternName()))
+}
+
+// Snooze moves the whole pattern of the sheet, so the outstanding review lands on the day the snooze ends
+// without an outstanding review the next one is put off by days instead
+func (p *PatternScheduler) Snooze(sheet *models.MemorySheet, days int, today time.Time) models.ReviewState {
+	state := sheet.Review
+	today = normalizeDate(today)
+	until := today.AddDate(0, 0, days)
+	if due, ok := p.LastDue(sheet, today); ok && isOutstanding(sheet, due, p.OverdueSince) {
+		// the reviews before the outstanding one move along too and would be outstanding right away,
+		// the snooze holds them back until the outstanding one comes due again
+		state.ShiftDays += int(until.Sub(due).Hours() / 24)
+		state.SnoozedUntil = until
+	} else {
+		// the reviews done before the next one move along too, the snooze holds them back the same way
+		after := today
+		if state.SnoozedUntil.After(today) {
+			after = state.SnoozedUntil.AddDate(0, 0, -1)
+		}
+		next := NextRemindingDate(p.start(sheet), after, p.Patterns.Of(sheet.PatternName()))
+		state.ShiftDays += days
+		state.SnoozedUntil = next.AddDate(0, 0, days)
+	}
+	return state
+}
+
+// start is the date the pattern of the sheet counts from, the sheet date moved by the days it was snoozed for
+func (p *PatternScheduler) start(sheet *models.MemorySheet) time.Time {
+	return sheet.Date.AddDate(0, 0, sheet.Review.ShiftDays)
+}
+
+// Grade only records the grade, the fixed pattern does not adapt to it
+func (p *PatternScheduler) Grade(sheet *models.MemorySheet, grade models.Grade, today time.Time) models.ReviewState {
+	state := sheet.Review
+	state.LastGrade = grade
+	state.LastReviewed = normalizeDate(today)
+	return state
+}
+
+const (
+	sm2InitialEase = 2.5
+	sm2MinimumEase = 1.3
+)
+
+// SM2Scheduler computes the next due date of each sheet from its own ease factor and interval history
+// sheets that were never graded are handed over to Fallback
+type SM2Scheduler struct {
+	Fallback Scheduler
+}
+
+func (s *SM2Scheduler) IsDue(sheet *models.MemorySheet, date time.Time) bool {
+	if !sheet.Review.IsGraded() {
+		return s.Fallback.IsDue(sheet, date)
+	}
+	return sheet.Review.Due.Equal(normalizeDate(date))
+}
+
+func (s *SM2Scheduler) LastDue(sheet *models.MemorySheet, today time.Time) (time.Time, bool) {
+	if !sheet.Review.IsGraded() {
+		return s.Fallback.LastDue(sheet, today)
+	}
+	if sheet.Review.Due.After(normalizeDate(today)) {
+		return time.Time{}, false
+	}
+	return sheet.Review.Due, true
+}
+
+// Snooze puts off the due date, an overdue sheet comes due days from today
+func (s *SM2Scheduler) Snooze(sheet *models.MemorySheet, days int, today time.Time) models.ReviewState {
+	if !sheet.Review.IsGraded() {
+		return s.Fallback.Snooze(sheet, days, today)
+	}
+	state := sheet.Review
+	from := normalizeDate(today)
+	if state.Due.After(from) {
+		from = state.Due
+	}
+	state.Due = from.AddDate(0, 0, days)
+	return state
+}
+
+func (s *SM2Scheduler) Grade(sheet *models.MemorySheet, grade models.Grade, today time.Time) models.ReviewState {
+	state := sheet.Review
+	if state.Ease == 0 {
+		state.Ease = sm2InitialEase
+	}
+
+	quality := gradeQuality(grade)
+	if quality < 3 {
+		// forgotten, start the interval ladder again
+		state.Repetitions = 0
+		state.Interval = 1
+	} else {
+		state.Repetitions++
+		switch state.Repetitions {
+		case 1:
+			state.Interval = 1
+		case 2:
+			state.Interval = 6
+		default:
+			state.Interval = int(math.Round(float64(state.Interval) * state.Ease))
+		}
+	}
+
+	miss := float64(5 - quality)
+	state.Ease = max(sm2MinimumEase, state.Ease+0.1-miss*(0.08+miss*0.02))
+
+	today = normalizeDate(today)
+	state.LastGrade = grade
+	state.LastReviewed = today
+	state.Due = today.AddDate(0, 0, state.Interval)
+	// the due date replaces the snooze of the pattern
+	state.SnoozedUntil = time.Time{}
+	return state
+}
+
+// gradeQuality maps a grade onto the 0-5 response quality scale of SM-2
+func gradeQuality(grade models.Grade) int {
+	switch grade {
+	case models.GradeAgain:
+		return 1
+	case models.GradeHard:
+		return 3
+	case models.GradeGood:
+		return 4
+	default:
+		return 5
+	}
+}
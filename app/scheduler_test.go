@@ -0,0 +1,118 @@
+package app
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+func TestSM2SchedulerGrade(t *testing.T) {
+	tests := []struct {
+		name            string
+		grades          []models.Grade
+		wantRepetitions int
+		wantInterval    int
+		wantEase        float64
+	}{
+		{"first good", []models.Grade{models.GradeGood}, 1, 1, 2.5},
+		{"first easy", []models.Grade{models.GradeEasy}, 1, 1, 2.6},
+		{"second good", []models.Grade{models.GradeGood, models.GradeGood}, 2, 6, 2.5},
+		{"third good", []models.Grade{models.GradeGood, models.GradeGood, models.GradeGood}, 3, 15, 2.5},
+		{"hard", []models.Grade{models.GradeGood, models.GradeGood, models.GradeHard}, 3, 15, 2.36},
+		{"again starts over", []models.Grade{models.GradeGood, models.GradeGood, models.GradeAgain}, 0, 1, 1.96},
+		{"ease stays above the minimum", []models.Grade{
+			models.GradeAgain, models.GradeAgain, models.GradeAgain, models.GradeAgain, models.GradeAgain,
+		}, 0, 1, sm2MinimumEase},
+	}
+	today := Today()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheduler := &SM2Scheduler{}
+			sheet := &models.MemorySheet{Date: today}
+			for _, grade := range tt.grades {
+				sheet.Review = scheduler.Grade(sheet, grade, today)
+			}
+			state := sheet.Review
+			if state.Repetitions != tt.wantRepetitions || state.Interval != tt.wantInterval || math.Abs(state.Ease-tt.wantEase) > 1e-9 {
+				t.Errorf("repetitions, interval, ease = %d, %d, %g, want %d, %d, %g",
+					state.Repetitions, state.Interval, state.Ease, tt.wantRepetitions, tt.wantInterval, tt.wantEase)
+			}
+			if want := today.AddDate(0, 0, tt.wantInterval); !state.Due.Equal(want) {
+				t.Errorf("due %s, want %s", state.Due.Format(time.DateOnly), want.Format(time.DateOnly))
+			}
+		})
+	}
+}
+
+func TestSM2SchedulerLastDue(t *testing.T) {
+	today := Today()
+	patterns := defaultPatternSet()
+	tests := []struct {
+		name    string
+		sheet   *models.MemorySheet
+		wantDue time.Time
+		wantOK  bool
+	}{
+		// the default pattern reminds on day 1, 2 and 4 of a sheet
+		{"never graded follows the pattern", &models.MemorySheet{Date: today.AddDate(0, 0, -3)}, today.AddDate(0, 0, -1), true},
+		{"never graded before the first reminder", &models.MemorySheet{Date: today}, time.Time{}, false},
+		{"graded and due", &models.MemorySheet{Date: today.AddDate(0, 0, -3), Review: models.ReviewState{
+			LastGrade: models.GradeGood, Due: today.AddDate(0, 0, -2),
+		}}, today.AddDate(0, 0, -2), true},
+		{"graded and not due yet", &models.MemorySheet{Date: today.AddDate(0, 0, -3), Review: models.ReviewState{
+			LastGrade: models.GradeGood, Due: today.AddDate(0, 0, 1),
+		}}, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due, ok := newScheduler(patterns, time.Time{}).LastDue(tt.sheet, today)
+			if ok != tt.wantOK || !due.Equal(tt.wantDue) {
+				t.Errorf("LastDue = %s, %v, want %s, %v", due.Format(time.DateOnly), ok, tt.wantDue.Format(time.DateOnly), tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestPatternSchedulerSnooze(t *testing.T) {
+	today := Today()
+	tests := []struct {
+		name         string
+		daysAgo      int
+		review       models.ReviewState
+		overdueSince time.Time
+		days         int
+		wantShift    int
+		wantNext     int
+	}{
+		// the default pattern reminds on day 1, 2, 4, 7 and 12 of a sheet
+		{"not due yet", 0, models.ReviewState{}, time.Time{}, 3, 3, 4},
+		{"due today", 2, models.ReviewState{}, time.Time{}, 1, 1, 1},
+		{"overdue", 3, models.ReviewState{}, time.Time{}, 2, 3, 2},
+		// the review of day 7 was missed before missed reviews counted, the one of day 12 moves by a day
+		{"missed before counting", 10, models.ReviewState{}, today, 1, 1, 3},
+		// the review of day 2 was done, the one of day 4 moves by two days and the earlier ones stay held back
+		{"reviewed since", 3, models.ReviewState{LastReviewed: today}, time.Time{}, 2, 2, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheduler := &PatternScheduler{Patterns: defaultPatternSet(), OverdueSince: tt.overdueSince}
+			sheet := &models.MemorySheet{Date: today.AddDate(0, 0, -tt.daysAgo), Review: tt.review}
+			sheet.Review = scheduler.Snooze(sheet, tt.days, today)
+			if sheet.Review.ShiftDays != tt.wantShift {
+				t.Errorf("shift days = %d, want %d", sheet.Review.ShiftDays, tt.wantShift)
+			}
+
+			next := -1
+			for day := 0; day <= 30 && next < 0; day++ {
+				if scheduler.IsDue(sheet, today.AddDate(0, 0, day)) {
+					next = day
+				}
+			}
+			if next != tt.wantNext {
+				t.Errorf("next reminder in %d days, want %d", next, tt.wantNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package app
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+// regexSearchPrefix switches a search to a case insensitive regex over the whole text, re:win.*func
+const regexSearchPrefix = "re:"
+
+// bm25 parameters, k1 is how fast repeating a term stops adding to the score, b how much long documents are held back
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// searchIndex is an inverted index of documents for ranked full text search
+// the terms are case folded and stemmed words, so "Reviewing" finds "reviews"
+type searchIndex struct {
+	mu sync.RWMutex
+	// postings has the frequency of each term in each document by document ID
+	postings map[string]map[string]int
+	// words counts the documents having each case folded word, a prefix is expanded into the terms of its words
+	words       map[string]int
+	docs        map[string]indexedDoc
+	totalLength int
+}
+
+type indexedDoc struct {
+	// length is the number of words of the document
+	length int
+	terms  map[string]int
+	words  []string
+}
+
+// searchHit is a document matching a search, the higher the score the more relevant
+type searchHit struct {
+	ID    string
+	Score float64
+	// Near is how many words of the query the document matched only by a near spelling
+	Near int
+}
+
+// token is a word of a text, start and end are its byte offsets in the text
+type token struct {
+	start, end int
+	word       string
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[string]int),
+		words:    make(map[string]int),
+		docs:     make(map[string]indexedDoc),
+	}
+}
+
+// tokenize splits text into its words of letters and digits, case folded
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			tokens = append(tokens, token{start: start, end: i, word: strings.ToLower(text[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{start: start, end: len(text), word: strings.ToLower(text[start:])})
+	}
+	return tokens
+}
+
+// Put indexes the text as the document of the id, replacing what the id had
+func (x *searchIndex) Put(id string, text string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.remove(id)
+	doc := indexedDoc{terms: make(map[string]int)}
+	seen := make(map[string]bool)
+	for _, token := range tokenize(text) {
+		doc.length++
+		doc.terms[stem(token.word)]++
+		if !seen[token.word] {
+			seen[token.word] = true
+			doc.words = append(doc.words, token.word)
+		}
+	}
+	for term, frequency := range doc.terms {
+		if x.postings[term] == nil {
+			x.postings[term] = make(map[string]int)
+		}
+		x.postings[term][id] = frequency
+	}
+	for _, word := range doc.words {
+		x.words[word]++
+	}
+	x.docs[id] = doc
+	x.totalLength += doc.length
+}
+
+// Delete drops the document of the id from the index
+func (x *searchIndex) Delete(id string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(id)
+}
+
+// remove drops the document of the id, the caller must hold x.mu
+func (x *searchIndex) remove(id string) {
+	doc, ok := x.docs[id]
+	if !ok {
+		return
+	}
+	for term := range doc.terms {
+		delete(x.postings[term], id)
+		if len(x.postings[term]) == 0 {
+			delete(x.postings, term)
+		}
+	}
+	for _, word := range doc.words {
+		if x.words[word]--; x.words[word] <= 0 {
+			delete(x.words, word)
+		}
+	}
+	x.totalLength -= doc.length
+	delete(x.docs, id)
+}
+
+// Search returns the documents having every word of the query, most relevant first by BM25
+// the last word also matches as the start of a longer word, so results show up while typing,
+// and a word ending in ~ also matches the words spelled nearly the same
+// the search stops with the error of ctx once it is done
+func (x *searchIndex) Search(ctx context.Context, query string) ([]searchHit, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	tokens := tokenize(query)
+	if len(tokens) == 0 || len(x.docs) == 0 {
+		return nil, nil
+	}
+	averageLength := float64(x.totalLength) / float64(len(x.docs))
+
+	var scores map[string]float64
+	// near counts the words of the query each document matched only nearly
+	near := make(map[string]int)
+	for i, token := range tokens {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		terms := []string{stem(token.word)}
+		if i == len(tokens)-1 {
+			terms = append(terms, x.prefixTerms(token.word)...)
+		}
+
+		// a word scores by its best matching term, a document must match every word
+		wordScores := make(map[string]float64)
+		for _, term := range terms {
+			x.scoreTerm(term, 1, averageLength, wordScores)
+		}
+		// a fuzzy word falls back on the near terms for the documents not having it
+		nearOnly := make(map[string]bool)
+		if isFuzzy(query, token) {
+			nearScores := make(map[string]float64)
+			for term, distance := range x.nearTerms(token.word) {
+				x.scoreTerm(term, fuzzyWeight/float64(distance), averageLength, nearScores)
+			}
+			for id, score := range nearScores {
+				if _, ok := wordScores[id]; !ok {
+					wordScores[id] = score
+					nearOnly[id] = true
+				}
+			}
+		}
+
+		if scores == nil {
+			scores = wordScores
+			for id := range nearOnly {
+				near[id]++
+			}
+			continue
+		}
+		for id := range scores {
+			if wordScore, ok := wordScores[id]; ok {
+				scores[id] += wordScore
+				if nearOnly[id] {
+					near[id]++
+				}
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := make([]searchHit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, searchHit{ID: id, Score: score, Near: near[id]})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].ranksBefore(hits[j]) {
+			return true
+		}
+		if hits[j].ranksBefore(hits[i]) {
+			return false
+		}
+		// the later sheet of equally relevant ones comes first
+		return hits[i].ID > hits[j].ID
+	})
+	return hits, nil
+}
+
+// ranksBefore tells if the hit is more relevant than other, exact matches come before near ones however relevant
+func (h searchHit) ranksBefore(other searchHit) bool {
+	if h.Near != other.Near {
+		return h.Near < other.Near
+	}
+	return h.Score > other.Score
+}
+
+// searchResult is a sheet or a nav sheet found by a search, with the hit that ranks it among the results of both
+type searchResult struct {
+	searchHit
+	models.SearchResult
+}
+
+// sortSearchResults orders the results of the sheets and the nav sheets together by their hits, like Search does
+// results of the same rank keep their order, so the results of a regex or of filters alone stay sheets first
+func sortSearchResults(results []searchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].ranksBefore(results[j].searchHit)
+	})
+}
+
+// scoreTerm adds the BM25 score of the term, times weight, to the scores of the documents having it
+// a document keeps its best score of the terms of a word, the caller must hold x.mu
+func (x *searchIndex) scoreTerm(term string, weight float64, averageLength float64, scores map[string]float64) {
+	postings := x.postings[term]
+	idf := math.Log(1 + (float64(len(x.docs))-float64(len(postings))+0.5)/(float64(len(postings))+0.5))
+	for id, frequency := range postings {
+		tf := float64(frequency)
+		norm := bm25K1 * (1 - bm25B + bm25B*float64(x.docs[id].length)/averageLength)
+		scores[id] = max(scores[id], weight*idf*tf*(bm25K1+1)/(tf+norm))
+	}
+}
+
+// prefixTerms returns the terms of the indexed words starting with prefix, the caller must hold x.mu
+func (x *searchIndex) prefixTerms(prefix string) []string {
+	var terms []string
+	for word := range x.words {
+		if len(word) > len(prefix) && strings.HasPrefix(word, prefix) {
+			terms = append(terms, stem(word))
+		}
+	}
+	return terms
+}
+
+// wordHighlighter highlights the words matching the query, nil when the query has no words
+// a word matches by its stem, or by starting with the last word of the query the way Search matches it,
+// or by being spelled nearly the same as a fuzzy word of the query
+func wordHighlighter(query string) models.Highlighter {
+	queryTokens := tokenize(query)
+	if len(queryTokens) == 0 {
+		return nil
+	}
+	terms := make(map[string]bool)
+	var fuzzyWords []string
+	for _, token := range queryTokens {
+		terms[stem(token.word)] = true
+		if isFuzzy(query, token) {
+			fuzzyWords = append(fuzzyWords, token.word)
+		}
+	}
+	prefix := queryTokens[len(queryTokens)-1].word
+
+	return func(text string) [][]int {
+		var matches [][]int
+		for _, token := range tokenize(text) {
+			if terms[stem(token.word)] || strings.HasPrefix(token.word, prefix) || isNear(token.word, fuzzyWords) {
+				matches = append(matches, []int{token.start, token.end})
+			}
+		}
+		return matches
+	}
+}
+
+// regexHighlighter highlights the matches of a regex search
+func regexHighlighter(re *regexp.Regexp) models.Highlighter {
+	return func(text string) [][]int {
+		return re.FindAllStringIndex(text, -1)
+	}
+}
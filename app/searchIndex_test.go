@@ -0,0 +1,124 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestSearchIndexSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		docs  map[string]string
+		query string
+		want  []string
+	}{
+		{"every word must match", map[string]string{
+			"a": "window functions in sql",
+			"b": "sql joins",
+		}, "sql window", []string{"a"}},
+		{"stems match", map[string]string{
+			"a": "reviewing the partitions",
+			"b": "nothing here",
+		}, "reviewed partition", []string{"a"}},
+		{"more occurrences rank first", map[string]string{
+			"a": "index notes about cats",
+			"b": "index index index notes",
+		}, "index", []string{"b", "a"}},
+		{"shorter documents rank first", map[string]string{
+			"a": "index of a long sheet with many other words in it",
+			"b": "index card",
+		}, "index", []string{"b", "a"}},
+		{"rare words weigh more", map[string]string{
+			"a": "goroutine goroutine channel",
+			"b": "goroutine channel channel",
+			"c": "goroutine",
+			"d": "goroutine",
+		}, "goroutine channel", []string{"b", "a"}},
+		{"the last word matches as a prefix", map[string]string{
+			"a": "partition",
+			"b": "party plans",
+			"c": "nothing",
+		}, "part", []string{"a", "b"}},
+		{"equal scores put the later id first", map[string]string{
+			"2025-01-01": "same text",
+			"2025-02-01": "same text",
+		}, "same", []string{"2025-02-01", "2025-01-01"}},
+		{"near matches come after exact ones", map[string]string{
+			"a": "partition",
+			"b": "partion partion partion",
+		}, "partion~ ", []string{"b", "a"}},
+		{"misspelled word without ~", map[string]string{
+			"a": "partition",
+		}, "partiton ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := newSearchIndex()
+			for id, text := range tt.docs {
+				x.Put(id, text)
+			}
+			hits, err := x.Search(context.Background(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, hit := range hits {
+				ids = append(ids, hit.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchIndexDelete(t *testing.T) {
+	x := newSearchIndex()
+	x.Put("a", "window functions")
+	x.Put("b", "window frames")
+	x.Delete("a")
+	// putting a document again replaces its words
+	x.Put("b", "joins")
+
+	for query, want := range map[string]int{"window": 0, "functions": 0, "joins": 1} {
+		hits, err := x.Search(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hits) != want {
+			t.Errorf("Search(%q) found %d documents, want %d", query, len(hits), want)
+		}
+	}
+}
+
+func TestWordHighlighter(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		text  string
+		want  []string
+	}{
+		{"stems match", "reviewed partition", "Reviewing the partitions, reviews and a review.",
+			[]string{"Reviewing", "partitions", "reviews", "review"}},
+		{"the last word matches as a prefix", "win", "window wine twin", []string{"window", "wine"}},
+		{"fuzzy words match nearly the same spelling", "partiton~ x", "partition party", []string{"partition"}},
+		{"multi-byte words", "café", "Café crème, CAFÉS and cafe", []string{"Café", "CAFÉS"}},
+		{"words without spaces", "日本", "日本語 の 日本", []string{"日本語", "日本"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, match := range wordHighlighter(tt.query)(tt.text) {
+				got = append(got, tt.text[match[0]:match[1]])
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("highlighted %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if wordHighlighter(" !! ") != nil {
+		t.Error("a query without words has a highlighter")
+	}
+}
@@ -0,0 +1,250 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+// maxSearchQueryLength caps the bytes of a search, which runs on every keystroke
+const maxSearchQueryLength = 256
+
+// maxSearchResults caps the results of a search, sheets and nav sheets together
+const maxSearchResults = 500
+
+// searchClause is a single condition of a search query, every clause of a query must hold
+type searchClause struct {
+	// Field is the filter name, tag, before, after, year, type or due, and empty for words and phrases
+	Field string
+	Value string
+	// Phrase is set for words in quotes, which must appear next to each other
+	Phrase bool
+	// Negated is set for clauses starting with -, which must not hold
+	Negated bool
+	// Date is the value of before: and after:, Year the value of year:
+	Date time.Time
+	Year int
+}
+
+// searchQuery is the parsed form of a search such as `window "partition by" tag:sql -draft after:2025-01-01`
+type searchQuery struct {
+	Clauses []searchClause
+	// Regex is set for queries starting with re:, a case insensitive regex over the whole text instead of clauses
+	Regex *regexp.Regexp
+}
+
+// searchTarget is a sheet or a nav sheet as the clauses of a query see it
+type searchTarget struct {
+	text   string
+	hasTag func(tag string) bool
+	// date is zero for nav sheets, which then never pass a date filter
+	date time.Time
+	nav  bool
+	due  bool
+	// stems are the stemmed words of text, worked out on the first clause that needs them
+	stems []string
+}
+
+// parseSearchQuery parses the text of the search box
+// words must all appear, "quoted words" must appear next to each other, a word ending in ~ may be misspelled,
+// and a leading - turns any clause around;
+// the filters are tag:sql, before:2025-12-01, after:2025-12-01, year:2025, type:sheet or type:nav and due:today
+// an unknown name before a colon is taken as a plain word
+func parseSearchQuery(text string) (*searchQuery, error) {
+	if len(text) > maxSearchQueryLength {
+		return nil, fmt.Errorf("the search is too long, keep it under %d characters", maxSearchQueryLength)
+	}
+	if pattern, ok := strings.CutPrefix(text, regexSearchPrefix); ok {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex pattern: %v", err)
+		}
+		return &searchQuery{Regex: re}, nil
+	}
+
+	query := &searchQuery{}
+	rest := strings.TrimSpace(text)
+	for rest != "" {
+		var clause searchClause
+		if len(rest) > 1 && rest[0] == '-' {
+			clause.Negated = true
+			rest = rest[1:]
+		}
+
+		var word string
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, errors.New(`a quoted phrase is missing its closing "`)
+			}
+			clause.Phrase = true
+			clause.Value = rest[1 : end+1]
+			rest = rest[end+2:]
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			word, rest = rest[:end], rest[end:]
+			if err := clause.parseWord(word); err != nil {
+				return nil, err
+			}
+		}
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+
+		if clause.Field == "" && len(tokenize(clause.Value)) == 0 {
+			// punctuation alone does not match anything
+			continue
+		}
+		query.Clauses = append(query.Clauses, clause)
+	}
+	return query, nil
+}
+
+// parseWord fills the clause from a word, which is a filter when it starts with a known filter name
+func (c *searchClause) parseWord(word string) error {
+	field, value, ok := strings.Cut(word, ":")
+	field = strings.ToLower(field)
+	switch {
+	case !ok:
+		c.Value = word
+		return nil
+	case field != "tag" && field != "before" && field != "after" && field != "year" && field != "type" && field != "due":
+		c.Value = word
+		return nil
+	case value == "":
+		return fmt.Errorf("%s: needs a value, such as %s", field, filterExamples[field])
+	}
+
+	c.Field = field
+	c.Value = value
+	var err error
+	switch field {
+	case "tag":
+		c.Value = models.NormalizeTag(value)
+	case "before", "after":
+		if c.Date, err = time.Parse(time.DateOnly, value); err != nil {
+			return fmt.Errorf("invalid date in %s, use a date like %s", word, filterExamples[field])
+		}
+	case "year":
+		if c.Year, err = strconv.Atoi(value); err != nil {
+			return fmt.Errorf("invalid year in %s, use a year like %s", word, filterExamples[field])
+		}
+	case "type":
+		c.Value = strings.ToLower(value)
+		if c.Value != "sheet" && c.Value != "nav" {
+			return fmt.Errorf("invalid type in %s, use type:sheet or type:nav", word)
+		}
+	case "due":
+		if strings.ToLower(value) != "today" {
+			return fmt.Errorf("invalid %s, only due:today is supported", word)
+		}
+	}
+	return nil
+}
+
+var filterExamples = map[string]string{
+	"tag":    "tag:sql",
+	"before": "before:2025-12-01",
+	"after":  "after:2025-12-01",
+	"year":   "year:2025",
+	"type":   "type:nav",
+	"due":    "due:today",
+}
+
+// Text returns the words and phrases the matching sheets must have, in the order of the query
+// these are searched through the index, which ranks the results by them
+func (q *searchQuery) Text() string {
+	var words []string
+	for _, clause := range q.Clauses {
+		if clause.Field == "" && !clause.Negated {
+			words = append(words, clause.Value)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Highlighter marks the matches of the query in a sheet, nil when the query has no words to mark
+func (q *searchQuery) Highlighter() models.Highlighter {
+	if q.Regex != nil {
+		return regexHighlighter(q.Regex)
+	}
+	return wordHighlighter(q.Text())
+}
+
+// needsDue tells if the query has a due: filter, which needs the sheets due today to be looked up
+func (q *searchQuery) needsDue() bool {
+	for _, clause := range q.Clauses {
+		if clause.Field == "due" {
+			return true
+		}
+	}
+	return false
+}
+
+// matches tells if the target holds every clause of the query
+// plain words are left out, the index already found the targets having them
+func (q *searchQuery) matches(target *searchTarget) bool {
+	for _, clause := range q.Clauses {
+		if clause.Field == "" && !clause.Phrase && !clause.Negated {
+			continue
+		}
+		if clause.holds(target) == clause.Negated {
+			return false
+		}
+	}
+	return true
+}
+
+func (c searchClause) holds(target *searchTarget) bool {
+	switch c.Field {
+	case "":
+		if target.stems == nil {
+			target.stems = stemsOf(target.text)
+		}
+		return hasPhrase(target.stems, stemsOf(c.Value))
+	case "tag":
+		return target.hasTag(c.Value)
+	case "before":
+		return !target.date.IsZero() && target.date.Before(c.Date)
+	case "after":
+		return !target.date.IsZero() && target.date.After(c.Date)
+	case "year":
+		return !target.date.IsZero() && target.date.Year() == c.Year
+	case "type":
+		return (c.Value == "nav") == target.nav
+	case "due":
+		return target.due
+	}
+	return false
+}
+
+// stemsOf returns the stemmed words of text in order
+func stemsOf(text string) []string {
+	tokens := tokenize(text)
+	stems := make([]string, len(tokens))
+	for i, token := range tokens {
+		stems[i] = stem(token.word)
+	}
+	return stems
+}
+
+// hasPhrase tells if the words of phrase appear next to each other in words
+func hasPhrase(words []string, phrase []string) bool {
+	if len(phrase) == 0 {
+		return true
+	}
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(phrase)], phrase) {
+			return true
+		}
+	}
+	return false
+}
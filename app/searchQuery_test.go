@@ -0,0 +1,91 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	date := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		query   string
+		want    []searchClause
+		wantErr string
+	}{
+		{"window sql", []searchClause{{Value: "window"}, {Value: "sql"}}, ""},
+		{`"partition by" -draft`, []searchClause{{Value: "partition by", Phrase: true}, {Value: "draft", Negated: true}}, ""},
+		{"tag:#SQL -type:nav", []searchClause{{Field: "tag", Value: "sql"}, {Field: "type", Value: "nav", Negated: true}}, ""},
+		{"after:2025-12-01 year:2025 due:today", []searchClause{
+			{Field: "after", Value: "2025-12-01", Date: date},
+			{Field: "year", Value: "2025", Year: 2025},
+			{Field: "due", Value: "today"},
+		}, ""},
+		{"http://example.com", []searchClause{{Value: "http://example.com"}}, ""},
+		{"sql - && x", []searchClause{{Value: "sql"}, {Value: "x"}}, ""},
+		{"  ", nil, ""},
+		{`"partition by`, nil, "missing its closing"},
+		{"tag:", nil, "tag: needs a value"},
+		{"before:yesterday", nil, "invalid date"},
+		{"year:last", nil, "invalid year"},
+		{"type:page", nil, "invalid type"},
+		{"due:tomorrow", nil, "only due:today"},
+		{strings.Repeat("x", maxSearchQueryLength+1), nil, "too long"},
+		{"re:(", nil, "invalid regex"},
+	}
+	for _, tt := range tests {
+		q, err := parseSearchQuery(tt.query)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseSearchQuery(%q) error = %v, want one with %q", tt.query, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSearchQuery(%q) error = %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(q.Clauses, tt.want) {
+			t.Errorf("parseSearchQuery(%q) = %+v, want %+v", tt.query, q.Clauses, tt.want)
+		}
+	}
+}
+
+func TestSearchQueryMatches(t *testing.T) {
+	sheet := &searchTarget{
+		text:   "Window functions: partition by and order by",
+		hasTag: func(tag string) bool { return tag == "sql" },
+		date:   time.Date(2025, 12, 14, 0, 0, 0, 0, time.UTC),
+	}
+	nav := &searchTarget{text: "shortcuts", hasTag: func(string) bool { return false }, nav: true}
+	tests := []struct {
+		query     string
+		wantSheet bool
+		wantNav   bool
+	}{
+		// plain words are left to the index, which already found the targets having them
+		{"window", true, true},
+		{`"partitioned by"`, true, false},
+		{`"by partition"`, false, false},
+		{`-"order by"`, false, true},
+		{"tag:sql", true, false},
+		{"-tag:sql", false, true},
+		{"after:2025-12-01 before:2026-01-01", true, false},
+		{"year:2024", false, false},
+		{"type:nav", false, true},
+		{"due:today", false, false},
+	}
+	for _, tt := range tests {
+		q, err := parseSearchQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := q.matches(sheet); got != tt.wantSheet {
+			t.Errorf("%q matches the sheet = %v, want %v", tt.query, got, tt.wantSheet)
+		}
+		if got := q.matches(nav); got != tt.wantNav {
+			t.Errorf("%q matches the nav sheet = %v, want %v", tt.query, got, tt.wantNav)
+		}
+	}
+}
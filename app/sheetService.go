@@ -1,12 +1,12 @@
 package app
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
-	"regexp"
+	"io/fs"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -14,233 +14,482 @@ import (
 	"github.com/linn221/memory-sheets/models"
 )
 
+// maxSnoozeDays is the longest a sheet can be snoozed for at once
+const maxSnoozeDays = 365
+
+// earliestSheetYear is the first year a sheet can be backfilled to, an earlier date is a typo rather than a note
+const earliestSheetYear = 2000
+
 type SheetService struct {
-	mu      sync.Mutex
-	pattern RemindPattern
-	dir     string
-	sheets  []*models.MemorySheet
+	mu        sync.Mutex
+	patterns  PatternSet
+	scheduler Scheduler
+	store     Store
+	// reviewLog is the review history, the ID of a deleted sheet with reviews is not given to a new one
+	reviewLog *ReviewLog
+	sheets    []*models.MemorySheet
+	// byID indexes sheets by their ID, so looking up a sheet does not scan the whole slice
+	byID map[string]*models.MemorySheet
+	// idByKey is the ID of the sheet read from each document key, kept after the sheet goes away
+	// a legacy sheet without a year directory got its year from the modification time it was read with,
+	// a later change of its document must not work the year out again
+	idByKey map[string]string
+	// index is the full text index of the sheets by ID, for ranked search
+	// a store with a full text index of its own is searched instead, but for the fuzzy words it knows nothing of
+	index *searchIndex
+	// overdueSince is the date missed reviews count from, the ones due before it are not reminded anymore
+	overdueSince time.Time
+	// detached keeps the state of the sheets whose files went away, in the trash or outside the app
+	// editors and sync tools often replace a file by removing it first, the state is attached again when it comes back
+	detached map[string]sheetState
 }
 
-// read the dir directory and scan sheets []*models.MemorySheet, store the sheets in SheetService
-// 2025/jan-1.md will be turned into models.MemorySheet of date (jan 1 2025 00:00:00 UTC), and Text will be the contents of the file
+// read the markdown documents of the store and scan sheets []*models.MemorySheet, store the sheets in SheetService
+// 2025/jan-1.md will be turned into models.MemorySheet of date (jan 1 2025 00:00:00 UTC), and Text will be the contents of the document
+// 2025/jan-1-2.md is the second sheet of that date
 // the sheets slice will always be ordered by Date field descending (latest first)
 func (s *SheetService) ReadDir() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.sheets = []*models.MemorySheet{}
+	s.byID = make(map[string]*models.MemorySheet)
+	s.idByKey = make(map[string]string)
+	s.index = newSearchIndex()
+	s.detached = make(map[string]sheetState)
 
-	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	items, err := s.store.List("")
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		// Only process .md documents, the deleted ones in the trash are not sheets anymore
+		if !strings.HasSuffix(item.Key, ".md") || isTrashKey(item.Key) {
+			continue
 		}
-
-		// Only process .md files
-		if !info.IsDir() && strings.HasSuffix(path, ".md") {
-			sheet, err := parseFilepathToSheet(s.dir, path)
-			if err != nil {
-				fmt.Printf("%s file does not get parsed for some reason: %v\n", path, err)
-			} else if sheet != nil {
-				s.sheets = append(s.sheets, sheet)
-			}
+		sheet, err := parseKeyToSheet(s.store, item)
+		if err != nil {
+			fmt.Printf("%s file does not get parsed for some reason: %v\n", item.Key, err)
+		} else if sheet != nil {
+			s.sheets = append(s.sheets, sheet)
+			s.byID[sheet.ID()] = sheet
+			s.idByKey[item.Key] = sheet.ID()
+			s.index.Put(sheet.ID(), sheet.Text)
 		}
+	}
+	s.sortSheets()
 
-		return nil
-	})
+	s.overdueSince, err = loadOverdueSince(s.store, Today())
+	if err != nil {
+		return err
+	}
+	s.scheduler = newScheduler(s.patterns, s.overdueSince)
 
-	if err == nil {
-		s.sortSheets()
+	// attach the persisted review state of each sheet
+	states, err := loadSheetStates(s.store)
+	if err != nil {
+		return err
+	}
+	for id, state := range states {
+		sheet, ok := s.byID[id]
+		if !ok {
+			// the sheet is in the trash, it gets the state back when it is restored
+			s.detached[id] = state
+			continue
+		}
+		sheet.Review = state.Review
+		sheet.Pattern = state.Pattern
 	}
 
-	return err
+	return nil
 }
 
+// LookUpSheets returns the sheets that are due on date, including the ones whose last scheduled review was missed
+// OverdueDays of the returned sheets tells how many days each review is late
 func (s *SheetService) LookUpSheets(date time.Time) ([]*models.MemorySheet, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.remindingSheets(s.scheduler, date), nil
+}
+
+// remindingSheets is LookUpSheets with the given scheduler, the caller must hold s.mu
+func (s *SheetService) remindingSheets(scheduler Scheduler, date time.Time) []*models.MemorySheet {
+	today := normalizeDate(date)
 	var remindingSheets []*models.MemorySheet
 	for _, sheet := range s.sheets {
-		sheetDate := sheet.Date
-		if IsDateReminding(sheetDate, date, s.pattern) {
-			remindingSheets = append(remindingSheets, sheet)
+		// archived sheets are not reminded anymore
+		if sheet.IsArchived() {
+			continue
+		}
+		// the latest scheduled review is outstanding until the sheet gets marked reviewed
+		due, ok := scheduler.LastDue(sheet, today)
+		if !ok || !isOutstanding(sheet, due, s.overdueSince) {
+			continue
 		}
+		remindingSheet := *sheet
+		remindingSheet.OverdueDays = int(today.Sub(due).Hours() / 24)
+		remindingSheets = append(remindingSheets, &remindingSheet)
 	}
-	return remindingSheets, nil
+
+	// most overdue first, sheets of the same lateness stay latest first
+	sort.SliceStable(remindingSheets, func(i, j int) bool {
+		return remindingSheets[i].OverdueDays > remindingSheets[j].OverdueDays
+	})
+	return remindingSheets
 }
 
-func (s *SheetService) CreateSheet(content string) error {
+// Forecast returns the sheets that come due on each of the next days, starting from the date from
+// the first day also carries the overdue sheets, the same as LookUpSheets
+func (s *SheetService) Forecast(from time.Time, days int) []models.ForecastDay {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
+	return s.forecast(s.scheduler, from, days)
+}
+
+// PreviewForecast is Forecast as if pattern was already saved as the pattern of the name
+func (s *SheetService) PreviewForecast(name string, pattern RemindPattern, from time.Time, days int) ([]models.ForecastDay, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	date := Today()
-	filepath := s.fromDateToFilepath(date)
+	if _, ok := s.patterns[name]; !ok {
+		return nil, fmt.Errorf("pattern %q does not exist", name)
+	}
+	candidate := s.patterns.Clone()
+	candidate[name] = pattern
+	return s.forecast(newScheduler(candidate, s.overdueSince), from, days), nil
+}
 
-	// Check if file exists
-	if fileExists(filepath) {
-		return fmt.Errorf("sheet already exist for date %s", date.Format(time.DateOnly))
+func (s *SheetService) forecast(scheduler Scheduler, from time.Time, days int) []models.ForecastDay {
+	from = normalizeDate(from)
+	forecast := make([]models.ForecastDay, 0, days)
+	for i := 0; i < days; i++ {
+		date := from.AddDate(0, 0, i)
+		if i == 0 {
+			forecast = append(forecast, models.ForecastDay{Date: date, Sheets: s.remindingSheets(scheduler, date)})
+			continue
+		}
+
+		day := models.ForecastDay{Date: date}
+		for _, sheet := range s.sheets {
+			if !sheet.IsArchived() && scheduler.IsDue(sheet, date) {
+				day.Sheets = append(day.Sheets, sheet)
+			}
+		}
+		forecast = append(forecast, day)
 	}
+	return forecast
+}
 
-	// Write the file
-	if err := writeFileContent(filepath, content); err != nil {
-		return err
+// CreateSheet writes content as a new sheet of the date and returns it
+// the date can be in the past to backfill notes learned earlier, back to earliestSheetYear, but not in the future
+// a day can have any number of sheets, each new one gets the next free sequence of the date
+func (s *SheetService) CreateSheet(date time.Time, content string) (*models.MemorySheet, error) {
+	date = normalizeDate(date)
+	if date.After(Today()) {
+		return nil, fmt.Errorf("cannot create a sheet for %s, it is in the future", date.Format(time.DateOnly))
+	}
+	if date.Year() < earliestSheetYear {
+		return nil, fmt.Errorf("cannot create a sheet for %s, sheets start from the year %d", date.Format(time.DateOnly), earliestSheetYear)
 	}
 
-	// Update in-memory sheet
-	normalizedDate := normalizeDate(date)
-	for _, sheet := range s.sheets {
-		if sheet.Date.Equal(normalizedDate) {
-			sheet.Text = content
-			return nil
-		}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// A broken front matter would be lost on the next read, reject it up front
+	meta, err := models.ParseFrontMatter(content)
+	if err != nil {
+		return nil, err
+	}
+
+	seq, err := s.nextSeq(date)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the document
+	if err := s.store.Put(s.fromDateToKey(date, seq), []byte(content)); err != nil {
+		return nil, err
 	}
 
-	// If not found in memory, add it
 	sheet := &models.MemorySheet{
-		Date: normalizedDate,
+		Date: date,
 		Year: date.Year(),
+		Seq:  seq,
 		Text: content,
+		Meta: meta,
 	}
-	s.insertSheetInOrder(sheet)
+	s.insertSheetInOrder(sheet, s.fromDateToKey(date, seq))
 
-	return nil
+	return sheet, nil
+}
+
+// nextSeq returns the sequence for a new sheet of the date, the caller must hold s.mu
+// the sequence of a deleted sheet is not given out again while its reviews, its state or its copy in the trash are
+// around, the new sheet would take over the history of the old one
+func (s *SheetService) nextSeq(date time.Time) (int, error) {
+	trashed, err := s.trashedKeys()
+	if err != nil {
+		return 0, err
+	}
+	for seq := 1; ; seq++ {
+		id := (&models.MemorySheet{Date: date, Seq: seq}).ID()
+		key := s.fromDateToKey(date, seq)
+		if _, ok := s.detached[id]; ok || s.byID[id] != nil || trashed[key] || s.reviewLog.HasSheet(id) {
+			continue
+		}
+		exists, err := storeHas(s.store, key)
+		if err != nil {
+			return 0, err
+		}
+		if !exists {
+			return seq, nil
+		}
+	}
+}
+
+// trashedKeys returns the keys the documents in the trash had before they were deleted
+func (s *SheetService) trashedKeys() (map[string]bool, error) {
+	items, err := s.store.List(trashPrefix)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool)
+	for _, item := range items {
+		if key, _, err := parseTrashKey(item.Key); err == nil {
+			keys[key] = true
+		}
+	}
+	return keys, nil
 }
 
 // update text file if it exists
-func (s *SheetService) UpdateSheet(date time.Time, content string) error {
+// the version is the one the edit was made on, an edit on a version that was changed in the meantime gets an EditConflict
+func (s *SheetService) UpdateSheet(id string, content string, version string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	filepath := s.fromDateToFilepath(date)
+	sheet, err := s.getSheet(id)
+	if err != nil {
+		return err
+	}
+	key := s.fromDateToKey(sheet.Date, sheet.Seq)
 
-	// Check if file exists
-	if !fileExists(filepath) {
-		return fmt.Errorf("sheet does not exist for date %s", date.Format(time.DateOnly))
+	// the saved version is checked rather than the one in memory, which may not have caught up with an outside edit yet
+	if err := checkVersion(s.store, key, version, content); err != nil {
+		return err
 	}
 
-	// Write the file
-	if err := writeFileContent(filepath, content); err != nil {
+	// A broken front matter would be lost on the next read, reject it up front
+	meta, err := models.ParseFrontMatter(content)
+	if err != nil {
+		return err
+	}
+
+	// Write the document
+	if err := s.store.Put(key, []byte(content)); err != nil {
 		return err
 	}
 
 	// Update in-memory sheet
-	normalizedDate := normalizeDate(date)
-	for _, sheet := range s.sheets {
-		if sheet.Date.Equal(normalizedDate) {
-			sheet.Text = content
-			return nil
-		}
+	updated := *sheet
+	updated.Text = content
+	updated.Meta = meta
+	s.replaceSheet(sheet, &updated)
+	return nil
+}
+
+// delete the file, it is kept in the trash until it is purged
+func (s *SheetService) DeleteSheet(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sheet, err := s.getSheet(id)
+	if err != nil {
+		return err
 	}
 
-	// If not found in memory, add it
-	sheet := &models.MemorySheet{
-		Date: normalizedDate,
-		Year: date.Year(),
-		Text: content,
+	// Move the document to the trash
+	if err := moveToTrash(s.store, s.fromDateToKey(sheet.Date, sheet.Seq)); err != nil {
+		return err
+	}
+
+	// Remove from in-memory sheets, the state is kept so a restored sheet carries on with its schedule
+	s.removeSheet(sheet)
+	if state := stateOf(sheet); !state.isZero() {
+		s.detached[sheet.ID()] = state
 	}
-	s.insertSheetInOrder(sheet)
 
 	return nil
 }
 
-// delete the file
-func (s *SheetService) DeleteSheet(date time.Time) error {
+// ForgetState drops the state kept for the sheet of a document purged from the trash
+func (s *SheetService) ForgetState(key string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	filepath := s.fromDateToFilepath(date)
+	sheet, err := parseSheetKey(s.keyItem(key))
+	if err != nil || sheet == nil {
+		return err
+	}
 
-	// Check if file exists
-	if !fileExists(filepath) {
-		return fmt.Errorf("sheet does not exist for date %s", date.Format(time.DateOnly))
+	if _, ok := s.detached[sheet.ID()]; !ok {
+		return nil
 	}
+	delete(s.detached, sheet.ID())
+	return s.saveStates()
+}
 
-	// Delete the file
-	if err := deleteFile(filepath); err != nil {
+// ReviewSheet grades how well the sheet of the id was remembered today
+// and persists the review state computed by the scheduler
+func (s *SheetService) ReviewSheet(id string, grade models.Grade) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sheet, err := s.getSheet(id)
+	if err != nil {
 		return err
 	}
+	updated := *sheet
+	updated.Review = s.scheduler.Grade(sheet, grade, Today())
+	s.replaceSheet(sheet, &updated)
+	return s.saveStates()
+}
 
-	// Remove from in-memory sheets
-	normalizedDate := normalizeDate(date)
-	for i, sheet := range s.sheets {
-		if sheet.Date.Equal(normalizedDate) {
-			s.sheets = append(s.sheets[:i], s.sheets[i+1:]...)
-			return nil
-		}
+// SnoozeSheet puts off the next review of the sheet of the id by days and persists the shifted schedule
+func (s *SheetService) SnoozeSheet(id string, days int) error {
+	if days < 1 || days > maxSnoozeDays {
+		return fmt.Errorf("a sheet can be snoozed for 1 to %d days", maxSnoozeDays)
 	}
 
-	return nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sheet, err := s.getSheet(id)
+	if err != nil {
+		return err
+	}
+	updated := *sheet
+	updated.Review = s.scheduler.Snooze(sheet, days, Today())
+	s.replaceSheet(sheet, &updated)
+	return s.saveStates()
 }
 
-// read the sheet
-// returns the index of the sheet in Sheets slice
-func (s *SheetService) GetSheetByDate(date time.Time) (*models.MemorySheet, error) {
+// saveStates persists the state of every sheet that has one
+func (s *SheetService) saveStates() error {
+	states := make(map[string]sheetState)
+	for id, state := range s.detached {
+		states[id] = state
+	}
+	for _, sheet := range s.sheets {
+		if state := stateOf(sheet); !state.isZero() {
+			states[sheet.ID()] = state
+		}
+	}
+	return saveSheetStates(s.store, states)
+}
+
+// GetSheetByID returns the sheet of the id, such as 2025-12-14 or 2025-12-14-2
+func (s *SheetService) GetSheetByID(id string) (*models.MemorySheet, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.getSheet(id)
+}
+
+// getSheet is GetSheetByID, the caller must hold s.mu
+func (s *SheetService) getSheet(id string) (*models.MemorySheet, error) {
+	date, seq, err := models.ParseSheetID(id)
+	if err != nil {
+		return nil, err
+	}
+
 	// Check in-memory sheets first
-	normalizedDate := normalizeDate(date)
-	for _, sheet := range s.sheets {
-		if sheet.Date.Equal(normalizedDate) {
-			return sheet, nil
-		}
+	if sheet, ok := s.byID[id]; ok {
+		return sheet, nil
 	}
 
-	// If not in memory, try to load from file
-	filepath := s.fromDateToFilepath(date)
-	if fileExists(filepath) {
-		index, err := s.loadSheetFromFile(date, filepath)
+	// If not in memory, try to load from the store
+	key := s.fromDateToKey(date, seq)
+	exists, err := storeHas(s.store, key)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		index, err := s.loadSheetFromStore(date, seq, key)
 		if err != nil {
 			return nil, err
 		}
 		return s.sheets[index], nil
 	}
 
-	return nil, fmt.Errorf("sheet does not exist for date %s", date.Format(time.DateOnly))
+	return nil, fmt.Errorf("sheet %s does not exist", id)
 }
 
-// read the sheet
-func (s *SheetService) IsSheetExist(date time.Time) bool {
-	filepath := s.fromDateToFilepath(date)
+// AllSheets returns every sheet, latest first
+func (s *SheetService) AllSheets() []*models.MemorySheet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*models.MemorySheet(nil), s.sheets...)
+}
 
+// SheetsOfDate returns the sheets of the date, latest created first
+func (s *SheetService) SheetsOfDate(date time.Time) []*models.MemorySheet {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Check in-memory sheets first
 	normalizedDate := normalizeDate(date)
+	var sheets []*models.MemorySheet
 	for _, sheet := range s.sheets {
 		if sheet.Date.Equal(normalizedDate) {
-			return true
+			sheets = append(sheets, sheet)
 		}
 	}
-
-	// If not in memory, check file system
-	return fileExists(filepath)
+	return sheets
 }
 
-// convert date to filepath, jan 01 2025 of time will be jan-01.md in the service directory
+// convert date and sequence to the store key, jan 01 2025 of time will be 2025/jan-1.md
+// the following sheets of the same date get their sequence appended, 2025/jan-1-2.md
 // make use of this method in Create/Update/DeleteSheet methods
-func (s *SheetService) fromDateToFilepath(date time.Time) string {
+func (s *SheetService) fromDateToKey(date time.Time, seq int) string {
 	month := strings.ToLower(date.Format("Jan"))
 	day := date.Day()
 	year := date.Year()
-	filename := fmt.Sprintf("%d/%s-%d.md", year, month, day)
-	return filepath.Join(s.dir, filename)
+	if seq > 1 {
+		return fmt.Sprintf("%d/%s-%d-%d.md", year, month, day, seq)
+	}
+	return fmt.Sprintf("%d/%s-%d.md", year, month, day)
+}
+
+// sheetBefore tells if sheet a comes before sheet b in the sheets slice
+// latest date first, and the latest created first among the sheets of the same date
+func sheetBefore(a *models.MemorySheet, b *models.MemorySheet) bool {
+	if !a.Date.Equal(b.Date) {
+		return a.Date.After(b.Date)
+	}
+	return a.Seq > b.Seq
 }
 
 // sortSheets sorts the sheets slice by Date field descending (latest first)
 func (s *SheetService) sortSheets() {
 	sort.Slice(s.sheets, func(i, j int) bool {
-		return s.sheets[i].Date.After(s.sheets[j].Date)
+		return sheetBefore(s.sheets[i], s.sheets[j])
 	})
 }
 
 // insertSheetInOrder inserts a sheet into the sheets slice at the correct position to maintain descending order by Date (latest first)
-func (s *SheetService) insertSheetInOrder(sheet *models.MemorySheet) {
+// key is the document the sheet was read from or written to
+// Returns the index where the sheet was inserted
+func (s *SheetService) insertSheetInOrder(sheet *models.MemorySheet, key string) int {
+	s.byID[sheet.ID()] = sheet
+	s.idByKey[key] = sheet.ID()
+	s.index.Put(sheet.ID(), sheet.Text)
+
 	// Find the insertion point
 	insertIndex := sort.Search(len(s.sheets), func(i int) bool {
-		return s.sheets[i].Date.Before(sheet.Date)
+		return sheetBefore(sheet, s.sheets[i])
 	})
 
 	// Insert at the found position
@@ -254,44 +503,79 @@ func (s *SheetService) insertSheetInOrder(sheet *models.MemorySheet) {
 		// Insert the new sheet
 		s.sheets[insertIndex] = sheet
 	}
+	return insertIndex
 }
 
-// loadSheetFromFile reads a sheet from the file system and stores it in the slice at its correct position
+// loadSheetFromStore reads a sheet from the store and keeps it in the slice at its correct position
 // Returns the index where the sheet was inserted
-func (s *SheetService) loadSheetFromFile(date time.Time, filepath string) (int, error) {
-	content, err := readFileContent(filepath)
+func (s *SheetService) loadSheetFromStore(date time.Time, seq int, key string) (int, error) {
+	content, err := s.store.Get(key)
 	if err != nil {
 		return -1, err
 	}
 
-	normalizedDate := normalizeDate(date)
 	sheet := &models.MemorySheet{
-		Date: normalizedDate,
+		Date: normalizeDate(date),
 		Year: date.Year(),
-		Text: content,
+		Seq:  seq,
+	}
+	if err := sheet.SetText(string(content)); err != nil {
+		return -1, err
 	}
 
-	// Find the insertion point before inserting (maintain descending order)
-	insertIndex := sort.Search(len(s.sheets), func(i int) bool {
-		return s.sheets[i].Date.Before(normalizedDate)
-	})
+	return s.insertSheetInOrder(sheet, key), nil
+}
 
-	// Insert at the found position
-	if insertIndex == len(s.sheets) {
-		s.sheets = append(s.sheets, sheet)
-	} else {
-		// Extend slice by one
-		s.sheets = append(s.sheets, nil)
-		// Shift elements to the right
-		copy(s.sheets[insertIndex+1:], s.sheets[insertIndex:])
-		// Insert the new sheet
-		s.sheets[insertIndex] = sheet
+type RemindPattern []int
+
+// ParseRemindPattern parses a comma separated list of intervals in days, such as "1, 1, 2, 3"
+// the intervals of a new pattern cannot get shorter, the patterns already saved are only checked by Validate
+func ParseRemindPattern(str string) (RemindPattern, error) {
+	var pattern RemindPattern
+	for _, field := range strings.Split(str, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		days, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval %q in pattern", field)
+		}
+		if len(pattern) > 0 && days < pattern[len(pattern)-1] {
+			return nil, fmt.Errorf("pattern intervals cannot get shorter, %d comes after %d", days, pattern[len(pattern)-1])
+		}
+		pattern = append(pattern, days)
 	}
+	if err := pattern.Validate(); err != nil {
+		return nil, err
+	}
+	return pattern, nil
+}
 
-	return insertIndex, nil
+// Validate checks the pattern can be walked by IsDateReminding
+// an empty pattern would panic, a negative interval or a pattern ending in 0 would never move past today
+func (p RemindPattern) Validate() error {
+	if len(p) == 0 {
+		return errors.New("pattern cannot be empty")
+	}
+	for _, days := range p {
+		if days < 0 {
+			return fmt.Errorf("pattern intervals cannot be negative, got %d", days)
+		}
+	}
+	if p[len(p)-1] == 0 {
+		return errors.New("pattern cannot end in 0")
+	}
+	return nil
 }
 
-type RemindPattern []int
+func (p RemindPattern) String() string {
+	intervals := make([]string, len(p))
+	for i, days := range p {
+		intervals[i] = strconv.Itoa(days)
+	}
+	return strings.Join(intervals, ", ")
+}
 
 func IsDateReminding(date time.Time, today time.Time, p RemindPattern) bool {
 	step := 0
@@ -308,95 +592,562 @@ func IsDateReminding(date time.Time, today time.Time, p RemindPattern) bool {
 	}
 }
 
-// UpdatePattern updates the pattern in memory
-func (s *SheetService) UpdatePattern(pattern RemindPattern) {
+// LastRemindingDate returns the most recent date on or before today that the pattern reminds the sheet of date
+func LastRemindingDate(date time.Time, today time.Time, p RemindPattern) (time.Time, bool) {
+	var last time.Time
+	found := false
+	step := 0
+	for {
+		distance := p[min(step, len(p)-1)]
+		date = date.AddDate(0, 0, distance)
+		if date.After(today) {
+			return last, found
+		}
+		last = date
+		found = true
+		step++
+	}
+}
+
+// NextRemindingDate returns the first date after after that the pattern reminds the sheet of date
+func NextRemindingDate(date time.Time, after time.Time, p RemindPattern) time.Time {
+	step := 0
+	for {
+		date = date.AddDate(0, 0, p[min(step, len(p)-1)])
+		if date.After(after) {
+			return date
+		}
+		step++
+	}
+}
+
+// GetPatterns returns a copy of the named patterns
+func (s *SheetService) GetPatterns() PatternSet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.patterns.Clone()
+}
+
+// UpdatePattern replaces the pattern of the name in memory
+func (s *SheetService) UpdatePattern(name string, pattern RemindPattern) error {
+	if err := pattern.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.patterns[name]; !ok {
+		return fmt.Errorf("pattern %q does not exist", name)
+	}
+	patterns := s.patterns.Clone()
+	patterns[name] = pattern
+	s.setPatterns(patterns)
+	return nil
+}
+
+// CreatePattern adds a new named pattern in memory
+func (s *SheetService) CreatePattern(name string, pattern RemindPattern) error {
+	if err := validatePatternName(name); err != nil {
+		return err
+	}
+	if err := pattern.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.patterns[name]; ok {
+		return fmt.Errorf("pattern %q already exists", name)
+	}
+	patterns := s.patterns.Clone()
+	patterns[name] = pattern
+	s.setPatterns(patterns)
+	return nil
+}
+
+// RenamePattern renames a pattern, the sheets following it keep following it under the new name
+func (s *SheetService) RenamePattern(oldName string, newName string) error {
+	if oldName == models.DefaultPatternName {
+		return errors.New("the default pattern cannot be renamed")
+	}
+	if err := validatePatternName(newName); err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.pattern = pattern
+
+	pattern, ok := s.patterns[oldName]
+	if !ok {
+		return fmt.Errorf("pattern %q does not exist", oldName)
+	}
+	if _, ok := s.patterns[newName]; ok {
+		return fmt.Errorf("pattern %q already exists", newName)
+	}
+	patterns := s.patterns.Clone()
+	delete(patterns, oldName)
+	patterns[newName] = pattern
+	s.setPatterns(patterns)
+
+	return s.reassignPattern(oldName, newName)
 }
 
-// GetPattern returns a copy of the current pattern
-func (s *SheetService) GetPattern() RemindPattern {
+// DeletePattern removes a pattern, the sheets following it go back to the default pattern
+func (s *SheetService) DeletePattern(name string) error {
+	if name == models.DefaultPatternName {
+		return errors.New("the default pattern cannot be deleted")
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	pattern := make(RemindPattern, len(s.pattern))
-	copy(pattern, s.pattern)
-	return pattern
+
+	if _, ok := s.patterns[name]; !ok {
+		return fmt.Errorf("pattern %q does not exist", name)
+	}
+	patterns := s.patterns.Clone()
+	delete(patterns, name)
+	s.setPatterns(patterns)
+
+	return s.reassignPattern(name, "")
 }
 
-// Search searches through all sheets using the provided regex pattern
-// Returns matching sheets with Text field modified to bold matched strings using markdown (**text**)
-func (s *SheetService) Search(patternStr string) ([]*models.MemorySheet, error) {
+// SetSheetPattern makes the sheet of the id follow the pattern of the name
+// the pattern is written to the front matter of the sheet
+func (s *SheetService) SetSheetPattern(id string, name string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if patternStr == "" {
-		return s.sheets, nil
+	if _, ok := s.patterns[name]; !ok {
+		return fmt.Errorf("pattern %q does not exist", name)
 	}
 
-	// Compile regex pattern with case-insensitive flag
-	re, err := regexp.Compile("(?i)" + patternStr)
+	sheet, err := s.getSheet(id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid regex pattern: %v", err)
+		return err
+	}
+	if sheet.Review.IsGraded() {
+		return fmt.Errorf("sheet %s is scheduled by its grades, a pattern only schedules the sheets never graded", id)
+	}
+	var value any
+	if name != models.DefaultPatternName {
+		value = name
 	}
+	sheet, err = s.setFrontMatterField(sheet, "pattern", value)
+	if err != nil {
+		return err
+	}
+	if sheet.Pattern == "" {
+		return nil
+	}
+	// the front matter replaces the pattern kept in the state file
+	updated := *sheet
+	updated.Pattern = ""
+	s.replaceSheet(sheet, &updated)
+	return s.saveStates()
+}
 
-	var matchingSheets []*models.MemorySheet
+// SetArchived archives the sheet of the id so it is not reminded anymore, or brings it back to its reviews
+// the flag is kept in the front matter of the sheet, archived: true
+func (s *SheetService) SetArchived(id string, archived bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sheet, err := s.getSheet(id)
+	if err != nil {
+		return err
+	}
+	var value any
+	if archived {
+		value = true
+	}
+	_, err = s.setFrontMatterField(sheet, "archived", value)
+	return err
+}
+
+// ArchivedSheets returns the archived sheets, latest first
+func (s *SheetService) ArchivedSheets() []*models.MemorySheet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sheets []*models.MemorySheet
 	for _, sheet := range s.sheets {
-		// Check if the pattern matches the text
-		if re.MatchString(sheet.Text) {
-			// Create a copy of the sheet with highlighted matches
-			highlightedText := re.ReplaceAllStringFunc(sheet.Text, func(match string) string {
-				return "**" + match + "**"
-			})
-
-			// Create a new sheet with highlighted text
-			highlightedSheet := &models.MemorySheet{
-				Date: sheet.Date,
-				Year: sheet.Year,
-				Text: highlightedText,
+		if sheet.IsArchived() {
+			sheets = append(sheets, sheet)
+		}
+	}
+	return sheets
+}
+
+// setFrontMatterField writes a single front matter field of the sheet to its document, the caller must hold s.mu
+// it returns the sheet that replaced the given one
+func (s *SheetService) setFrontMatterField(sheet *models.MemorySheet, key string, value any) (*models.MemorySheet, error) {
+	content, err := models.SetFrontMatterField(sheet.Text, key, value)
+	if err != nil {
+		return nil, err
+	}
+	updated := *sheet
+	if err := updated.SetText(content); err != nil {
+		return nil, err
+	}
+	if err := s.store.Put(s.fromDateToKey(sheet.Date, sheet.Seq), []byte(content)); err != nil {
+		return nil, err
+	}
+	s.replaceSheet(sheet, &updated)
+	return &updated, nil
+}
+
+// setPatterns swaps in the patterns along with a scheduler using them, the caller must hold s.mu
+func (s *SheetService) setPatterns(patterns PatternSet) {
+	s.patterns = patterns
+	s.scheduler = newScheduler(patterns, s.overdueSince)
+}
+
+// reassignPattern moves the sheets following oldName over to newName and persists them, the caller must hold s.mu
+// an empty newName puts the sheets back on the default pattern
+func (s *SheetService) reassignPattern(oldName string, newName string) error {
+	var value any
+	if newName != "" {
+		value = newName
+	}
+
+	changed := false
+	for _, sheet := range s.sheets {
+		if sheet.Meta.Pattern == oldName {
+			var err error
+			if sheet, err = s.setFrontMatterField(sheet, "pattern", value); err != nil {
+				return err
 			}
-			matchingSheets = append(matchingSheets, highlightedSheet)
+		}
+		if sheet.Pattern == oldName {
+			updated := *sheet
+			updated.Pattern = newName
+			s.replaceSheet(sheet, &updated)
+			changed = true
 		}
 	}
+	if !changed {
+		return nil
+	}
+	return s.saveStates()
+}
+
+// SheetsByTag returns the sheets carrying the tag, latest first
+func (s *SheetService) SheetsByTag(tag string) []*models.MemorySheet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return matchingSheets, nil
+	var sheets []*models.MemorySheet
+	for _, sheet := range s.sheets {
+		if sheet.HasTag(tag) {
+			sheets = append(sheets, sheet)
+		}
+	}
+	return sheets
+}
+
+// CountTags adds the number of sheets carrying each tag to counts
+func (s *SheetService) CountTags(counts map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sheet := range s.sheets {
+		for _, tag := range sheet.Tags() {
+			counts[tag]++
+		}
+	}
 }
 
-// LoadPatternFromJSON loads pattern from a JSON file
-func LoadPatternFromJSON(path string) (RemindPattern, error) {
-	data, err := os.ReadFile(path)
+// Search returns up to limit sheets matching the query, the most relevant first by the words and phrases of the query
+// a query of filters alone returns the sheets passing them latest first, the same as a regex query
+// the sheets are copied under the lock and scanned without it, so a long search does not hold up edits,
+// and the scan stops with the error of ctx once it is done
+// Returns copies of the matching sheets with Highlight set to mark the matched words, along with their hits
+func (s *SheetService) Search(ctx context.Context, query *searchQuery, limit int) ([]searchResult, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	text := query.Text()
+	ranked := query.Regex == nil && text != ""
+	var hits []searchHit
+	if ranked {
+		var err error
+		hits, err = s.searchHits(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	var candidates []models.MemorySheet
+	var candidateHits []searchHit
+	if ranked {
+		candidates = make([]models.MemorySheet, 0, len(hits))
+		for _, hit := range hits {
+			if sheet, ok := s.byID[hit.ID]; ok {
+				candidates = append(candidates, *sheet)
+				candidateHits = append(candidateHits, hit)
+			}
+		}
+	} else {
+		candidates = make([]models.MemorySheet, 0, len(s.sheets))
+		for _, sheet := range s.sheets {
+			candidates = append(candidates, *sheet)
+			candidateHits = append(candidateHits, searchHit{ID: sheet.ID()})
+		}
+	}
+	due := make(map[string]bool)
+	if query.needsDue() {
+		for _, sheet := range s.remindingSheets(s.scheduler, Today()) {
+			due[sheet.ID()] = true
+		}
+	}
+	s.mu.Unlock()
+
+	highlight := query.Highlighter()
+	var results []searchResult
+	for i := range candidates {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		sheet := &candidates[i]
+		if query.Regex != nil {
+			if !query.Regex.MatchString(sheet.Text) {
+				continue
+			}
+		} else {
+			target := &searchTarget{
+				text:   sheet.Text,
+				hasTag: sheet.HasTag,
+				date:   sheet.Date,
+				due:    due[sheet.ID()],
+			}
+			if !query.matches(target) {
+				continue
+			}
+		}
+		sheet.Highlight = highlight
+		results = append(results, searchResult{
+			searchHit:    candidateHits[i],
+			SearchResult: models.SearchResult{Sheet: sheet},
+		})
+		if len(results) == limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+// searchHits ranks the sheets having the words of text, by the full text index of the store when it keeps one
+// the store knows nothing of near spellings, a text with fuzzy words goes through the index of the service
+func (s *SheetService) searchHits(ctx context.Context, text string) ([]searchHit, error) {
+	searcher, ok := s.store.(storeSearcher)
+	if !ok || hasFuzzyWords(text) {
+		return s.index.Search(ctx, text)
+	}
+	keyHits, err := searcher.Search(ctx, text)
 	if err != nil {
-		if os.IsNotExist(err) {
-			// Return default pattern if file doesn't exist
-			return RemindPattern{1, 1, 2, 3, 5, 8, 13, 21, 34, 55, 89}, nil
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the documents of nav sheets and of the trash are not sheets
+	hits := make([]searchHit, 0, len(keyHits))
+	for _, hit := range keyHits {
+		if id, ok := s.idByKey[hit.ID]; ok && s.byID[id] != nil {
+			hit.ID = id
+			hits = append(hits, hit)
 		}
+	}
+	return hits, nil
+}
+
+// errHistoryOff is returned by the history methods when the store does not keep revisions
+var errHistoryOff = errors.New("history is off, turn it on by making the sheets directory a git repository (git init sheets)")
+
+// HasHistory tells if the store keeps the earlier revisions of the sheets
+func (s *SheetService) HasHistory() bool {
+	_, ok := s.store.(historyStore)
+	return ok
+}
+
+// SheetHistory returns the revisions of the sheet of the id, latest first
+func (s *SheetService) SheetHistory(id string) ([]models.Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history, key, err := s.historyOf(id)
+	if err != nil {
 		return nil, err
 	}
+	return history.History(key)
+}
 
-	var pattern RemindPattern
-	if err := json.Unmarshal(data, &pattern); err != nil {
-		return nil, fmt.Errorf("failed to parse pattern JSON: %v", err)
+// SheetRevision returns the content of the sheet of the id as of the revision
+func (s *SheetService) SheetRevision(id string, hash string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history, key, err := s.historyOf(id)
+	if err != nil {
+		return "", err
+	}
+	content, err := history.Revision(key, hash)
+	if err != nil {
+		return "", err
 	}
+	return string(content), nil
+}
 
-	return pattern, nil
+// SheetDiff returns the diff of the sheet of the id from one revision to another
+// an empty from diffs the revision to against the one before it
+func (s *SheetService) SheetDiff(id string, from string, to string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history, key, err := s.historyOf(id)
+	if err != nil {
+		return "", err
+	}
+	return history.Diff(key, from, to)
 }
 
-// SavePatternToJSON saves pattern to a JSON file
-func SavePatternToJSON(path string, pattern RemindPattern) error {
-	data, err := json.MarshalIndent(pattern, "", "  ")
+// historyOf returns the history of the store along with the key of the sheet of the id, the caller must hold s.mu
+// the sheet does not have to exist anymore, the history of a deleted sheet is still there
+func (s *SheetService) historyOf(id string) (historyStore, string, error) {
+	history, ok := s.store.(historyStore)
+	if !ok {
+		return nil, "", errHistoryOff
+	}
+	date, seq, err := models.ParseSheetID(id)
 	if err != nil {
-		return fmt.Errorf("failed to marshal pattern: %v", err)
+		return nil, "", err
 	}
+	return history, s.fromDateToKey(date, seq), nil
+}
 
-	// Ensure directory exists
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %v", err)
+// Reload brings the sheet of the key in line with the store after its document was changed outside the app
+// the sheet is replaced rather than changed in place, so a sheet being rendered is never seen half updated
+func (s *SheetService) Reload(key string) error {
+	if !strings.HasSuffix(key, ".md") {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item := s.keyItem(key)
+	sheet, err := parseKeyToSheet(s.store, item)
+	if errors.Is(err, fs.ErrNotExist) {
+		gone, err := parseSheetKey(item)
+		if err != nil || gone == nil {
+			return err
+		}
+		if old, ok := s.byID[gone.ID()]; ok {
+			s.removeSheet(old)
+			if state := stateOf(old); !state.isZero() {
+				s.detached[old.ID()] = state
+			}
+		}
+		return nil
 	}
+	if err != nil || sheet == nil {
+		return err
+	}
+	id := sheet.ID()
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
-		return fmt.Errorf("failed to write pattern file: %v", err)
+	if old, ok := s.byID[id]; ok {
+		if old.Text == sheet.Text {
+			// the app wrote it itself
+			return nil
+		}
+		sheet.Review = old.Review
+		sheet.Pattern = old.Pattern
+		s.replaceSheet(old, sheet)
+		return nil
 	}
 
+	if state, ok := s.detached[id]; ok {
+		sheet.Review = state.Review
+		sheet.Pattern = state.Pattern
+		delete(s.detached, id)
+	} else if states, err := loadSheetStates(s.store); err == nil {
+		// a sheet synced from another machine can come with its state
+		if state, ok := states[id]; ok {
+			sheet.Review = state.Review
+			sheet.Pattern = state.Pattern
+		}
+	}
+	s.insertSheetInOrder(sheet, key)
 	return nil
 }
+
+// keyItem returns the item to parse the sheet of the document key from, the caller must hold s.mu
+// a sheet read from the key before keeps its year; otherwise the document was modified just now,
+// which is the year of a new legacy sheet without a year directory
+func (s *SheetService) keyItem(key string) StoreItem {
+	item := StoreItem{Key: key, ModTime: time.Now()}
+	if id, ok := s.idByKey[key]; ok {
+		if date, _, err := models.ParseSheetID(id); err == nil {
+			item.ModTime = date
+		}
+	}
+	return item
+}
+
+// ReloadStates attaches the states of the state document again after it was changed outside the app
+func (s *SheetService) ReloadStates() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states, err := loadSheetStates(s.store)
+	if err != nil {
+		return err
+	}
+	for i, sheet := range s.sheets {
+		state := states[sheet.ID()]
+		if state == stateOf(sheet) {
+			continue
+		}
+		updated := *sheet
+		updated.Review = state.Review
+		updated.Pattern = state.Pattern
+		s.sheets[i] = &updated
+		s.byID[sheet.ID()] = &updated
+	}
+	for id := range s.detached {
+		if _, ok := states[id]; !ok {
+			delete(s.detached, id)
+		}
+	}
+	return nil
+}
+
+// replaceSheet puts updated in the place of sheet, the caller must hold s.mu
+// sheets are replaced rather than changed in place, a sheet handed out before may still be being rendered
+func (s *SheetService) replaceSheet(sheet *models.MemorySheet, updated *models.MemorySheet) {
+	i := sort.Search(len(s.sheets), func(i int) bool {
+		return !sheetBefore(s.sheets[i], sheet)
+	})
+	if i < len(s.sheets) && s.sheets[i] == sheet {
+		s.sheets[i] = updated
+	}
+	s.byID[updated.ID()] = updated
+	if updated.Text != sheet.Text {
+		s.index.Put(updated.ID(), updated.Text)
+	}
+}
+
+// removeSheet takes the sheet out of the slice, byID and the search index, the caller must hold s.mu
+func (s *SheetService) removeSheet(sheet *models.MemorySheet) {
+	for i := range s.sheets {
+		if s.sheets[i] == sheet {
+			s.sheets = append(s.sheets[:i], s.sheets[i+1:]...)
+			break
+		}
+	}
+	delete(s.byID, sheet.ID())
+	s.index.Delete(sheet.ID())
+}
@@ -0,0 +1,444 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+// newTestSheetService reads the sheets of the store with the default patterns
+func newTestSheetService(t *testing.T, store Store) *SheetService {
+	t.Helper()
+	reviewLog, err := NewReviewLog(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &SheetService{patterns: defaultPatternSet(), store: store, reviewLog: reviewLog}
+	if err := s.ReadDir(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSheetServiceEdits(t *testing.T) {
+	store := NewMemoryStore()
+	s := newTestSheetService(t, store)
+	date := Today().AddDate(0, 0, -1)
+
+	if _, err := s.CreateSheet(Today().AddDate(0, 0, 1), "text"); err == nil {
+		t.Error("CreateSheet of a future date succeeded")
+	}
+	if _, err := s.CreateSheet(date, "---\ntags: [\n---\ntext"); err == nil {
+		t.Error("CreateSheet with a broken front matter succeeded")
+	}
+
+	first, err := s.CreateSheet(date, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.CreateSheet(date, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID() != date.Format(time.DateOnly) || second.ID() != date.Format(time.DateOnly)+"-2" {
+		t.Fatalf("IDs of the sheets of a date = %s, %s", first.ID(), second.ID())
+	}
+	if sheets := s.SheetsOfDate(date); len(sheets) != 2 || sheets[0].ID() != second.ID() {
+		t.Errorf("sheets of the date = %v, want the latest created first", sheetIDs(sheets))
+	}
+
+	version := models.ContentVersion("first")
+	if err := s.UpdateSheet(first.ID(), "edited", version); err != nil {
+		t.Fatal(err)
+	}
+	// an edit on the version before gets the saved text back
+	var conflict *EditConflict
+	if err := s.UpdateSheet(first.ID(), "edited elsewhere", version); !errors.As(err, &conflict) || conflict.Saved != "edited" {
+		t.Errorf("UpdateSheet on a stale version = %v, want an edit conflict", err)
+	}
+	if sheet, err := s.GetSheetByID(first.ID()); err != nil || sheet.Text != "edited" {
+		t.Errorf("GetSheetByID after the edit = %v, %v", sheet, err)
+	}
+
+	if err := s.DeleteSheet(first.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetSheetByID(first.ID()); err == nil {
+		t.Error("GetSheetByID of a deleted sheet succeeded")
+	}
+	if _, err := store.Get(s.fromDateToKey(date, 1)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("the document of the deleted sheet is still in place: %v", err)
+	}
+	if items, err := store.List(trashPrefix); err != nil || len(items) != 1 {
+		t.Errorf("trash after the delete = %v, %v, want the deleted sheet", items, err)
+	}
+}
+
+func TestSetSheetPatternOfAGradedSheet(t *testing.T) {
+	s := newTestSheetService(t, NewMemoryStore())
+	sheet, err := s.CreateSheet(Today().AddDate(0, 0, -1), "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetSheetPattern(sheet.ID(), "light"); err != nil {
+		t.Fatal(err)
+	}
+	if sheet, _ := s.GetSheetByID(sheet.ID()); sheet.PatternName() != "light" {
+		t.Errorf("pattern of the sheet = %s, want light", sheet.PatternName())
+	}
+
+	if err := s.ReviewSheet(sheet.ID(), models.GradeGood); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetSheetPattern(sheet.ID(), "intensive"); err == nil || !strings.Contains(err.Error(), "scheduled by its grades") {
+		t.Errorf("SetSheetPattern of a graded sheet = %v, want an error", err)
+	}
+	if sheet, _ := s.GetSheetByID(sheet.ID()); sheet.PatternName() != "light" {
+		t.Errorf("pattern of the graded sheet = %s, want light kept", sheet.PatternName())
+	}
+}
+
+func TestSheetChangesReplaceTheSheet(t *testing.T) {
+	s := newTestSheetService(t, NewMemoryStore())
+	sheet, err := s.CreateSheet(Today().AddDate(0, 0, -1), "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := sheet.ID()
+	changes := map[string]func() error{
+		"update":  func() error { return s.UpdateSheet(id, "edited", "") },
+		"pattern": func() error { return s.SetSheetPattern(id, "light") },
+		"snooze":  func() error { return s.SnoozeSheet(id, 2) },
+		"archive": func() error { return s.SetArchived(id, true) },
+		"review":  func() error { return s.ReviewSheet(id, models.GradeGood) },
+	}
+	for _, name := range []string{"update", "pattern", "snooze", "archive", "review"} {
+		before, err := s.GetSheetByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a sheet being rendered while it changes must be seen as it was
+		want := *before
+		if err := changes[name](); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(*before, want) {
+			t.Errorf("%s changed the sheet handed out before it", name)
+		}
+		if after, err := s.GetSheetByID(id); err != nil || reflect.DeepEqual(*after, want) {
+			t.Errorf("%s left the sheet as it was: %v", name, err)
+		}
+	}
+}
+
+func TestSetFrontMatterFieldOfASheet(t *testing.T) {
+	date := normalizeDate(Today().AddDate(0, 0, -1))
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{"no front matter", "body", "---\narchived: true\n---\nbody", false},
+		{"unknown keys kept in order", "---\nsource: book\ntags: [sql]\nlevel: 2\n---\nbody",
+			"---\nsource: book\ntags: [sql]\nlevel: 2\narchived: true\n---\nbody", false},
+		{"malformed block", "---\ntags: [sql\n---\nbody", "---\ntags: [sql\n---\nbody", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			s := newTestSheetService(t, store)
+			// written outside the app, a malformed block is loaded with only its text
+			key := s.fromDateToKey(date, 1)
+			if err := store.Put(key, []byte(tt.text)); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Reload(key); err != nil {
+				t.Fatal(err)
+			}
+			id := date.Format(time.DateOnly)
+
+			err := s.SetArchived(id, true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetArchived error = %v, want error %v", err, tt.wantErr)
+			}
+			if data, _ := store.Get(key); string(data) != tt.want {
+				t.Errorf("document = %q, want %q", data, tt.want)
+			}
+			sheet, err := s.GetSheetByID(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sheet.Text != tt.want || sheet.IsArchived() == tt.wantErr {
+				t.Errorf("sheet text, archived = %q, %v, want %q, %v", sheet.Text, sheet.IsArchived(), tt.want, !tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReloadKeepsTheYearOfALegacySheet(t *testing.T) {
+	store := NewMemoryStore()
+	lastYear := time.Now().AddDate(-1, 0, 0)
+	store.docs["dec-14.md"] = memoryDoc{data: []byte("before"), modTime: lastYear}
+	s := newTestSheetService(t, store)
+	id := fmt.Sprintf("%d-12-14", lastYear.Year())
+
+	// an outside edit gives the document this year's modification time
+	if err := store.Put("dec-14.md", []byte("after")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reload("dec-14.md"); err != nil {
+		t.Fatal(err)
+	}
+	sheets := s.AllSheets()
+	if len(sheets) != 1 || sheets[0].ID() != id || sheets[0].Text != "after" {
+		t.Fatalf("sheets after the edit = %v, want only %s with the new text", sheetIDs(sheets), id)
+	}
+
+	if err := store.Delete("dec-14.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reload("dec-14.md"); err != nil {
+		t.Fatal(err)
+	}
+	if sheets := s.AllSheets(); len(sheets) != 0 {
+		t.Fatalf("sheets after the removal = %v, want none", sheetIDs(sheets))
+	}
+}
+
+func TestForgetStateOfALegacySheet(t *testing.T) {
+	store := NewMemoryStore()
+	lastYear := time.Now().AddDate(-1, 0, 0)
+	store.docs["dec-14.md"] = memoryDoc{data: []byte("text"), modTime: lastYear}
+	s := newTestSheetService(t, store)
+	id := fmt.Sprintf("%d-12-14", lastYear.Year())
+
+	if err := s.ReviewSheet(id, models.GradeGood); err != nil {
+		t.Fatal(err)
+	}
+	// the document goes away outside the app, its state is kept until it is forgotten
+	if err := store.Delete("dec-14.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reload("dec-14.md"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.detached[id]; !ok {
+		t.Fatalf("the state of the removed sheet %s is not kept", id)
+	}
+	if err := s.ForgetState("dec-14.md"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.detached[id]; ok {
+		t.Fatalf("the forgotten state of %s is still kept", id)
+	}
+}
+
+func TestCreateSheetSkipsTheIDOfADeletedSheet(t *testing.T) {
+	date := normalizeDate(Today().AddDate(0, 0, -3))
+	tests := []struct {
+		name        string
+		reviewed    bool
+		emptyTrash  bool
+		wantNextSeq int
+	}{
+		{"in the trash", false, false, 3},
+		{"with reviews", true, true, 3},
+		{"gone for good", false, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			s := newTestSheetService(t, store)
+			for range 2 {
+				if _, err := s.CreateSheet(date, "text"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			deleted := (&models.MemorySheet{Date: date, Seq: 2}).ID()
+			if tt.reviewed {
+				entry := models.ReviewEntry{SheetID: deleted, SheetDate: date, ReviewedAt: time.Now()}
+				if err := s.reviewLog.Append(entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.DeleteSheet(deleted); err != nil {
+				t.Fatal(err)
+			}
+			if tt.emptyTrash {
+				items, err := store.List(trashPrefix)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, item := range items {
+					if err := store.Delete(item.Key); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			sheet, err := s.CreateSheet(date, "new text")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sheet.Seq != tt.wantNextSeq {
+				t.Errorf("the new sheet got seq %d, want %d", sheet.Seq, tt.wantNextSeq)
+			}
+		})
+	}
+}
+
+func TestLookUpSheetsCountsMissedReviewsFromTheFirstRun(t *testing.T) {
+	today := Today()
+	tests := []struct {
+		name         string
+		overdueSince time.Time
+		daysAgo      int
+		wantOverdue  []int
+	}{
+		// the default pattern reminds on day 1, 2 and 4 of a sheet
+		{"first run, missed before", time.Time{}, 3, []int{}},
+		{"first run, due today", time.Time{}, 4, []int{0}},
+		{"missed since", today.AddDate(0, 0, -10), 3, []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			if !tt.overdueSince.IsZero() {
+				store.docs[overdueSinceKey] = memoryDoc{data: []byte(tt.overdueSince.Format(time.DateOnly)), modTime: time.Now()}
+			}
+			s := newTestSheetService(t, store)
+			if _, err := s.CreateSheet(today.AddDate(0, 0, -tt.daysAgo), "text"); err != nil {
+				t.Fatal(err)
+			}
+
+			sheets, err := s.LookUpSheets(today)
+			if err != nil {
+				t.Fatal(err)
+			}
+			overdue := []int{}
+			for _, sheet := range sheets {
+				overdue = append(overdue, sheet.OverdueDays)
+			}
+			if fmt.Sprint(overdue) != fmt.Sprint(tt.wantOverdue) {
+				t.Errorf("overdue days of the reminded sheets = %v, want %v", overdue, tt.wantOverdue)
+			}
+		})
+	}
+}
+
+func sheetIDs(sheets []*models.MemorySheet) []string {
+	ids := make([]string, len(sheets))
+	for i, sheet := range sheets {
+		ids[i] = sheet.ID()
+	}
+	return ids
+}
+
+func TestForecast(t *testing.T) {
+	s := newTestSheetService(t, NewMemoryStore())
+	today := Today()
+	yesterday := today.AddDate(0, 0, -1)
+	create := func(date time.Time, content string) string {
+		t.Helper()
+		sheet, err := s.CreateSheet(date, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sheet.ID()
+	}
+	// the default pattern reminds on day 1, 2, 4, 7 and 12 of a sheet, light on day 3 and 10
+	fresh := create(today, "fresh")
+	create(today, "---\narchived: true\n---\narchived")
+	older := create(yesterday, "older")
+	light := create(yesterday, "---\npattern: light\n---\nlight")
+	graded := create(yesterday, "graded")
+	if err := s.ReviewSheet(graded, models.GradeGood); err != nil {
+		t.Fatal(err)
+	}
+
+	days := func(forecast []models.ForecastDay) [][]string {
+		var ids [][]string
+		for _, day := range forecast {
+			ids = append(ids, sheetIDs(day.Sheets))
+		}
+		return ids
+	}
+	// latest sheets first within a day
+	baseline := [][]string{{older}, {fresh, graded, older}, {fresh, light}, {older}, {fresh}, {}, {older}, {fresh}}
+	if got := days(s.Forecast(today, 8)); !reflect.DeepEqual(got, baseline) {
+		t.Fatalf("forecast = %q, want %q", got, baseline)
+	}
+
+	// the graded sheet and the one on another pattern keep their days
+	preview, err := s.PreviewForecast(models.DefaultPatternName, RemindPattern{2, 3}, today, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{}, {graded, older}, {fresh, light}, {}, {older}, {fresh}, {}, {older}}
+	if got := days(preview); !reflect.DeepEqual(got, want) {
+		t.Errorf("preview = %q, want %q", got, want)
+	}
+	if got := days(s.Forecast(today, 8)); !reflect.DeepEqual(got, baseline) {
+		t.Errorf("forecast after the preview = %q, want %q", got, baseline)
+	}
+	if _, err := s.PreviewForecast("unknown", RemindPattern{2, 3}, today, 8); err == nil {
+		t.Error("PreviewForecast of an unknown pattern succeeded")
+	}
+}
+
+func TestSearchLimit(t *testing.T) {
+	services := map[string]func(t *testing.T) func(context.Context, *searchQuery, int) ([]searchResult, error){
+		"sheets": func(t *testing.T) func(context.Context, *searchQuery, int) ([]searchResult, error) {
+			s := newTestSheetService(t, NewMemoryStore())
+			for i := range 5 {
+				if _, err := s.CreateSheet(Today().AddDate(0, 0, -i), fmt.Sprintf("note %d", i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return s.Search
+		},
+		"nav sheets": func(t *testing.T) func(context.Context, *searchQuery, int) ([]searchResult, error) {
+			s := newTestNavSheetService(t, NewMemoryStore())
+			for i := range 5 {
+				if err := s.Create(fmt.Sprintf("page-%d", i), fmt.Sprintf("note %d", i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			return s.Search
+		},
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for name, newService := range services {
+		t.Run(name, func(t *testing.T) {
+			search := newService(t)
+			// ranked by the index, matched by a regex, and filtered without words
+			for _, q := range []string{"note", "re:note", "-draft"} {
+				query, err := parseSearchQuery(q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, limit := range []int{0, 1, 3, 5, 10} {
+					results, err := search(context.Background(), query, limit)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if want := min(limit, 5); len(results) != want {
+						t.Errorf("Search(%q) with limit %d = %d results, want %d", q, limit, len(results), want)
+					}
+				}
+				if results, err := search(cancelled, query, 10); !errors.Is(err, context.Canceled) {
+					t.Errorf("Search(%q) with a cancelled context = %d results, %v, want %v", q, len(results), err, context.Canceled)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+// stateKey is the document of the store that keeps per sheet scheduling state
+const stateKey = "state.json"
+
+// overdueSinceKey is the document of the store with the date the app started to keep track of missed reviews
+// the reviews due before it were missed while nothing recorded which ones got done, they are not overdue
+const overdueSinceKey = "overdue-since.txt"
+
+// sheetState is what gets persisted for a single sheet, keyed by the sheet ID
+type sheetState struct {
+	Review  models.ReviewState `json:"review,omitzero"`
+	Pattern string             `json:"pattern,omitempty"`
+}
+
+func stateOf(sheet *models.MemorySheet) sheetState {
+	return sheetState{
+		Review:  sheet.Review,
+		Pattern: sheet.Pattern,
+	}
+}
+
+func (st sheetState) isZero() bool {
+	return st.Review == models.ReviewState{} && st.Pattern == ""
+}
+
+// loadSheetStates loads the per sheet states from the JSON document of the store
+// a missing document means no sheet has any state yet
+func loadSheetStates(store Store) (map[string]sheetState, error) {
+	states := make(map[string]sheetState)
+	data, err := store.Get(stateKey)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return states, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("failed to parse sheet state JSON: %v", err)
+	}
+	return states, nil
+}
+
+// saveSheetStates saves the per sheet states to the JSON document of the store
+func saveSheetStates(store Store, states map[string]sheetState) error {
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal sheet state: %v", err)
+	}
+
+	if err := store.Put(stateKey, data); err != nil {
+		return fmt.Errorf("failed to write sheet state file: %v", err)
+	}
+	return nil
+}
+
+// loadOverdueSince returns the date missed reviews count from, a store without one starts counting on today
+func loadOverdueSince(store Store, today time.Time) (time.Time, error) {
+	data, err := store.Get(overdueSinceKey)
+	if err == nil {
+		since, err := time.Parse(time.DateOnly, strings.TrimSpace(string(data)))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse %s: %v", overdueSinceKey, err)
+		}
+		return since, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return time.Time{}, err
+	}
+
+	today = normalizeDate(today)
+	if err := store.Put(overdueSinceKey, []byte(today.Format(time.DateOnly)+"\n")); err != nil {
+		return time.Time{}, fmt.Errorf("failed to write %s: %v", overdueSinceKey, err)
+	}
+	return today, nil
+}
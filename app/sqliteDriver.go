@@ -0,0 +1,6 @@
+//go:build sqlite_fts5
+
+package app
+
+// the driver needs cgo, so it is only built in on request, the sqlite_fts5 tag also turns on FTS5 in the driver
+import _ "github.com/mattn/go-sqlite3"
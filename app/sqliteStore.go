@@ -0,0 +1,224 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+)
+
+// sqliteDriver is registered by sqliteDriver.go, which is only built with -tags sqlite_fts5
+const sqliteDriver = "sqlite3"
+
+// sqliteSchema keeps every document in one table, the markdown documents are also indexed by FTS5 for search
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS documents (
+	key      TEXT PRIMARY KEY,
+	data     BLOB NOT NULL,
+	mod_time INTEGER NOT NULL
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS documents_fts USING fts5(
+	key UNINDEXED,
+	body,
+	tokenize = 'porter unicode61'
+);
+`
+
+// SQLiteStore keeps the documents in a SQLite database, for instances with too many sheets to walk a directory
+// the sheets, nav sheets, per sheet state, review log and patterns all live in the documents table
+type SQLiteStore struct {
+	db       *sql.DB
+	watchers storeWatchers
+}
+
+// OpenSQLiteStore opens the SQLite database at path, creating it and its tables if needed
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	if !slices.Contains(sql.Drivers(), sqliteDriver) {
+		return nil, errors.New("the SQLite store is not built in, build with -tags sqlite_fts5")
+	}
+	db, err := sql.Open(sqliteDriver, "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	// a single connection serializes the writes, SQLite allows one writer at a time anyway
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create the tables of %s: %v", path, err)
+	}
+	if err := fillSearchTable(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to index the documents of %s: %v", path, err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// fillSearchTable indexes the markdown documents when the search table is empty,
+// as it is in the databases of the versions that went without it
+func fillSearchTable(db *sql.DB) error {
+	var indexed bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM documents_fts)`).Scan(&indexed); err != nil || indexed {
+		return err
+	}
+	_, err := db.Exec(`INSERT INTO documents_fts (key, body)
+		SELECT key, CAST(data AS TEXT) FROM documents WHERE key LIKE '%.md'`)
+	return err
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) List(prefix string) ([]StoreItem, error) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	query := `SELECT key, mod_time FROM documents ORDER BY key`
+	var args []any
+	if prefix != "" {
+		query = `SELECT key, mod_time FROM documents WHERE substr(key, 1, ?) = ? ORDER BY key`
+		args = []any{len(prefix) + 1, prefix + "/"}
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []StoreItem
+	for rows.Next() {
+		var item StoreItem
+		var modTime int64
+		if err := rows.Scan(&item.Key, &modTime); err != nil {
+			return nil, err
+		}
+		item.ModTime = time.Unix(0, modTime)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *SQLiteStore) Get(key string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM documents WHERE key = ?`, key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", key, fs.ErrNotExist)
+	}
+	return data, err
+}
+
+func (s *SQLiteStore) Put(key string, data []byte) error {
+	if key == "" {
+		return fmt.Errorf("invalid key: %s", key)
+	}
+
+	err := s.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO documents (key, data, mod_time) VALUES (?, ?, ?)
+			ON CONFLICT (key) DO UPDATE SET data = excluded.data, mod_time = excluded.mod_time`,
+			key, data, time.Now().UnixNano())
+		if err != nil {
+			return err
+		}
+		return s.index(tx, key, data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save %s, the previous version is kept: %v", key, err)
+	}
+	s.watchers.notify(StoreEvent{Key: key, Op: StorePut})
+	return nil
+}
+
+// Append adds data to the end of the document, creating it if needed
+func (s *SQLiteStore) Append(key string, data []byte) error {
+	_, err := s.db.Exec(`INSERT INTO documents (key, data, mod_time) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET data = data || excluded.data, mod_time = excluded.mod_time`,
+		key, data, time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %v", key, err)
+	}
+	s.watchers.notify(StoreEvent{Key: key, Op: StorePut})
+	return nil
+}
+
+func (s *SQLiteStore) Delete(key string) error {
+	err := s.inTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM documents WHERE key = ?`, key)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("%s: %w", key, fs.ErrNotExist)
+		}
+		_, err = tx.Exec(`DELETE FROM documents_fts WHERE key = ?`, key)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.watchers.notify(StoreEvent{Key: key, Op: StoreDelete})
+	return nil
+}
+
+func (s *SQLiteStore) Watch(ctx context.Context) (<-chan StoreEvent, error) {
+	return s.watchers.add(ctx), nil
+}
+
+// Search returns the hits of the markdown documents having every word of the query by their keys, most relevant first
+// the last word also matches as a prefix, and words match by their stems like in the index of the services
+func (s *SQLiteStore) Search(ctx context.Context, query string) ([]searchHit, error) {
+	var terms []string
+	for _, token := range tokenize(query) {
+		// quoting keeps FTS5 operators in the query from being a syntax error
+		terms = append(terms, `"`+token.word+`"`)
+	}
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	terms[len(terms)-1] += "*"
+
+	// bm25 gives the better matches the lower scores
+	rows, err := s.db.QueryContext(ctx, `SELECT key, -bm25(documents_fts) FROM documents_fts
+		WHERE documents_fts MATCH ? ORDER BY bm25(documents_fts)`, strings.Join(terms, " "))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %v", err)
+	}
+	defer rows.Close()
+
+	var hits []searchHit
+	for rows.Next() {
+		var hit searchHit
+		if err := rows.Scan(&hit.ID, &hit.Score); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// index replaces the full text entry of the document, only markdown documents are indexed
+func (s *SQLiteStore) index(tx *sql.Tx, key string, data []byte) error {
+	if _, err := tx.Exec(`DELETE FROM documents_fts WHERE key = ?`, key); err != nil {
+		return err
+	}
+	if !strings.HasSuffix(key, ".md") {
+		return nil
+	}
+	_, err := tx.Exec(`INSERT INTO documents_fts (key, body) VALUES (?, ?)`, key, string(data))
+	return err
+}
+
+func (s *SQLiteStore) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,136 @@
+//go:build sqlite_fts5
+
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func openTestSQLiteStore(t *testing.T, path string) *SQLiteStore {
+	t.Helper()
+	store, err := OpenSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func searchKeys(t *testing.T, store *SQLiteStore, query string) []string {
+	t.Helper()
+	hits, err := store.Search(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, hit := range hits {
+		keys = append(keys, hit.ID)
+	}
+	return keys
+}
+
+func TestSQLiteStoreSearch(t *testing.T) {
+	store := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "sheets.db"))
+	docs := map[string]string{
+		"2025/dec-13.md": "Window functions partition the rows",
+		"2025/dec-14.md": "htmx swaps the target",
+		"nav/sql.md":     "partitions and windows",
+		"state.json":     `{"partition": true}`,
+	}
+	for key, text := range docs {
+		if err := store.Put(key, []byte(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"partition", []string{"2025/dec-13.md", "nav/sql.md"}},
+		{"window partitioning", []string{"2025/dec-13.md", "nav/sql.md"}},
+		{"swap targ", []string{"2025/dec-14.md"}},
+		{`"OR" AND NOT*`, nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got := searchKeys(t, store, tt.query)
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	if err := store.Delete("nav/sql.md"); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchKeys(t, store, "partition"); !slices.Equal(got, []string{"2025/dec-13.md"}) {
+		t.Errorf("Search after deleting nav/sql.md = %q", got)
+	}
+}
+
+func TestSQLiteStoreIndexesADatabaseWithoutTheSearchTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sheets.db")
+	store := openTestSQLiteStore(t, path)
+	if err := store.Put("2025/dec-13.md", []byte("window functions")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.db.Exec(`DROP TABLE documents_fts`); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store = openTestSQLiteStore(t, path)
+	if got := searchKeys(t, store, "window"); !slices.Equal(got, []string{"2025/dec-13.md"}) {
+		t.Errorf("Search after reopening = %q", got)
+	}
+	// opening again leaves the filled table as it is
+	store.Close()
+	store = openTestSQLiteStore(t, path)
+	if got := searchKeys(t, store, "window"); !slices.Equal(got, []string{"2025/dec-13.md"}) {
+		t.Errorf("Search after reopening twice = %q", got)
+	}
+}
+
+func TestSheetServiceSearchesThroughTheSQLiteIndex(t *testing.T) {
+	store := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "sheets.db"))
+	for key, text := range map[string]string{
+		"2025/dec-13.md": "Window functions partition the rows",
+		"2025/dec-14.md": "htmx swaps the target",
+		"nav/sql.md":     "partition",
+		".trash/20251214T000000.000000000/2025/dec-15.md": "partition",
+	} {
+		if err := store.Put(key, []byte(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestSheetService(t, store)
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"partition", []string{"2025-12-13"}},
+		{"partiton~", []string{"2025-12-13"}},
+		{"swap", []string{"2025-12-14"}},
+	} {
+		query, err := parseSearchQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := s.Search(context.Background(), query, maxSearchResults)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, result := range results {
+			got = append(got, result.Sheet.ID())
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
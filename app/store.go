@@ -0,0 +1,189 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+// Store keeps the documents behind the services: markdown sheets, nav sheets, the state file and the review log
+// documents are addressed by keys, slash separated paths relative to the root of the store such as "2025/dec-14.md"
+type Store interface {
+	// List returns the documents under the prefix directory, an empty prefix lists the whole store
+	List(prefix string) ([]StoreItem, error)
+	// Get returns the content of the document, a missing document is an error matching fs.ErrNotExist
+	Get(key string) ([]byte, error)
+	// Put creates or replaces the document
+	Put(key string, data []byte) error
+	// Delete removes the document, a missing document is an error matching fs.ErrNotExist
+	Delete(key string) error
+	// Watch reports the changed documents until ctx is done
+	Watch(ctx context.Context) (<-chan StoreEvent, error)
+}
+
+// storeAppender is implemented by stores that can add to the end of a document without rewriting it
+type storeAppender interface {
+	Append(key string, data []byte) error
+}
+
+// storeSearcher is implemented by stores keeping a full text index of the markdown documents
+type storeSearcher interface {
+	// Search returns the hits of the documents having every word of the query by their keys, most relevant first
+	// the last word also matches as a prefix
+	Search(ctx context.Context, query string) ([]searchHit, error)
+}
+
+// historyStore is implemented by stores keeping the earlier revisions of the documents
+type historyStore interface {
+	// History returns the revisions of the document, latest first
+	History(key string) ([]models.Revision, error)
+	// Revision returns the content of the document as of the revision
+	Revision(key string, hash string) ([]byte, error)
+	// Diff returns the unified diff of the document from one revision to another, an empty from diffs against the revision before
+	Diff(key string, from string, to string) (string, error)
+}
+
+// StoreItem is a single document listed by Store.List
+type StoreItem struct {
+	Key     string
+	ModTime time.Time
+}
+
+type StoreOp int
+
+const (
+	StorePut StoreOp = iota
+	StoreDelete
+)
+
+// StoreEvent tells a document of the key was put or deleted
+type StoreEvent struct {
+	Key string
+	Op  StoreOp
+}
+
+// storeHas tells if the store has a document of the key
+// other errors are returned, a document that cannot be read is not known to be missing
+func storeHas(store Store, key string) (bool, error) {
+	_, err := store.Get(key)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return false, err
+}
+
+// CopyStore copies every document of src to dst, returning how many were copied
+func CopyStore(dst Store, src Store) (int, error) {
+	items, err := src.List("")
+	if err != nil {
+		return 0, err
+	}
+	for i, item := range items {
+		data, err := src.Get(item.Key)
+		if err != nil {
+			return i, err
+		}
+		if err := dst.Put(item.Key, data); err != nil {
+			return i, fmt.Errorf("failed to copy %s: %v", item.Key, err)
+		}
+	}
+	return len(items), nil
+}
+
+// storeWatchers fans the events of a store out to its watchers
+type storeWatchers struct {
+	mu       sync.Mutex
+	watchers map[*storeWatcher]struct{}
+}
+
+// storeWatcher holds the events a watcher has not taken yet, one per key with the latest op
+// a burst of changes such as a sync of many files is neither dropped nor blocks the store, the changes of a key
+// made before the watcher caught up come as one event
+type storeWatcher struct {
+	mu      sync.Mutex
+	keys    []string
+	pending map[string]StoreOp
+	// wake tells the watcher there are pending events
+	wake chan struct{}
+}
+
+// add registers a watcher that is dropped once ctx is done
+func (w *storeWatchers) add(ctx context.Context) <-chan StoreEvent {
+	watcher := &storeWatcher{pending: make(map[string]StoreOp), wake: make(chan struct{}, 1)}
+	w.mu.Lock()
+	if w.watchers == nil {
+		w.watchers = make(map[*storeWatcher]struct{})
+	}
+	w.watchers[watcher] = struct{}{}
+	w.mu.Unlock()
+
+	events := make(chan StoreEvent)
+	go func() {
+		defer close(events)
+		defer func() {
+			w.mu.Lock()
+			delete(w.watchers, watcher)
+			w.mu.Unlock()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-watcher.wake:
+			}
+			for _, event := range watcher.take() {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events
+}
+
+// notify hands the event to every watcher without waiting for any of them
+func (w *storeWatchers) notify(event StoreEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for watcher := range w.watchers {
+		watcher.put(event)
+	}
+}
+
+// put adds the event to the pending ones, replacing the one of the same key not taken yet
+func (w *storeWatcher) put(event StoreEvent) {
+	w.mu.Lock()
+	if _, ok := w.pending[event.Key]; !ok {
+		w.keys = append(w.keys, event.Key)
+	}
+	w.pending[event.Key] = event.Op
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the pending events in the order their keys first changed and clears them
+func (w *storeWatcher) take() []StoreEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	events := make([]StoreEvent, len(w.keys))
+	for i, key := range w.keys {
+		events[i] = StoreEvent{Key: key, Op: w.pending[key]}
+	}
+	w.keys = nil
+	clear(w.pending)
+	return events
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemoryStore() },
+		"file":   func(t *testing.T) Store { return NewFileStore(t.TempDir()) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			if _, err := store.Get("2025/dec-14.md"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Get of a missing document = %v, want fs.ErrNotExist", err)
+			}
+			for _, key := range []string{"2025/dec-14.md", "2025/dec-14-2.md", "nav/go/basics.md", "navigation.md"} {
+				if err := store.Put(key, []byte("text of "+key)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := store.Put("2025/dec-14.md", []byte("replaced")); err != nil {
+				t.Fatal(err)
+			}
+			if data, err := store.Get("2025/dec-14.md"); err != nil || string(data) != "replaced" {
+				t.Errorf("Get of a replaced document = %q, %v", data, err)
+			}
+
+			tests := []struct {
+				prefix string
+				want   []string
+			}{
+				{"", []string{"2025/dec-14-2.md", "2025/dec-14.md", "nav/go/basics.md", "navigation.md"}},
+				{"nav", []string{"nav/go/basics.md"}},
+				{"2025", []string{"2025/dec-14-2.md", "2025/dec-14.md"}},
+				{"2026", nil},
+			}
+			for _, tt := range tests {
+				items, err := store.List(tt.prefix)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var keys []string
+				for _, item := range items {
+					keys = append(keys, item.Key)
+				}
+				if fmt.Sprint(keys) != fmt.Sprint(tt.want) {
+					t.Errorf("List(%q) = %v, want %v", tt.prefix, keys, tt.want)
+				}
+			}
+
+			if err := store.Delete("2025/dec-14.md"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Get("2025/dec-14.md"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Get of a deleted document = %v, want fs.ErrNotExist", err)
+			}
+			if err := store.Delete("2025/dec-14.md"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Delete of a missing document = %v, want fs.ErrNotExist", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// trashPurgeInterval is how often the trash is checked for documents past the retention
+const trashPurgeInterval = time.Hour
+
+// SetTrashRetention keeps deleted sheets in the trash for retention, zero keeps them until they are purged by hand
+// the documents already past the new retention are purged right away
+func (a *App) SetTrashRetention(retention time.Duration) error {
+	a.trash.SetRetention(retention)
+	return a.purgeExpiredTrash(time.Now())
+}
+
+// purgeTrashEvery purges the documents past the retention every interval until ctx is done
+func (a *App) purgeTrashEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := a.purgeExpiredTrash(now); err != nil {
+				fmt.Printf("Warning: failed to purge the trash: %v\n", err)
+			}
+		}
+	}
+}
+
+// purgeExpiredTrash purges the documents deleted longer than the retention ago
+func (a *App) purgeExpiredTrash(now time.Time) error {
+	items, err := a.trash.List()
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if item.PurgeAt.IsZero() || item.PurgeAt.After(now) {
+			continue
+		}
+		if err := a.purgeTrash(item.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeTrash deletes the document from the trash for good, along with the state kept for it
+func (a *App) purgeTrash(trashKey string) error {
+	key, err := a.trash.Purge(trashKey)
+	if err != nil {
+		return err
+	}
+	return a.sheetService.ForgetState(key)
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+// trashPrefix is the directory of the store that keeps the deleted documents, under the time of the deletion
+// .trash/20251214T093000.000000000/2025/dec-14.md is 2025/dec-14.md deleted on Dec 14 2025 09:30 UTC
+const trashPrefix = ".trash"
+
+const trashTimeFormat = "20060102T150405.000000000"
+
+// defaultTrashRetention is how long deleted documents are kept unless configured otherwise
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+type TrashService struct {
+	mu    sync.Mutex
+	store Store
+	// retention is how long deleted documents are kept, zero keeps them until they are purged by hand
+	retention time.Duration
+}
+
+func isTrashKey(key string) bool {
+	return strings.HasPrefix(key, trashPrefix+"/")
+}
+
+// parseTrashKey returns the key the document had before it was deleted and the time of the deletion
+func parseTrashKey(trashKey string) (string, time.Time, error) {
+	rest, ok := strings.CutPrefix(trashKey, trashPrefix+"/")
+	// a key from a request must not reach out of the trash with ..
+	if !ok || path.Clean(trashKey) != trashKey {
+		return "", time.Time{}, fmt.Errorf("invalid trash key: %s", trashKey)
+	}
+	stamp, key, ok := strings.Cut(rest, "/")
+	if !ok || key == "" {
+		return "", time.Time{}, fmt.Errorf("invalid trash key: %s", trashKey)
+	}
+	deletedAt, err := time.Parse(trashTimeFormat, stamp)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid trash key: %s", trashKey)
+	}
+	return key, deletedAt, nil
+}
+
+// moveToTrash deletes the document of the key, keeping it in the trash
+func moveToTrash(store Store, key string) error {
+	data, err := store.Get(key)
+	if err != nil {
+		return err
+	}
+	trashKey := trashPrefix + "/" + time.Now().UTC().Format(trashTimeFormat) + "/" + key
+	if err := store.Put(trashKey, data); err != nil {
+		return err
+	}
+	if err := store.Delete(key); err != nil {
+		// the document is still in place, it should not show up in the trash as well
+		store.Delete(trashKey)
+		return err
+	}
+	return nil
+}
+
+// Retention returns how long deleted documents are kept, zero when they are kept until purged by hand
+func (t *TrashService) Retention() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.retention
+}
+
+func (t *TrashService) SetRetention(retention time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.retention = max(0, retention)
+}
+
+// List returns the documents in the trash, the latest deleted first
+func (t *TrashService) List() ([]models.TrashItem, error) {
+	retention := t.Retention()
+	items, err := t.store.List(trashPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var trash []models.TrashItem
+	for _, item := range items {
+		key, deletedAt, err := parseTrashKey(item.Key)
+		if err != nil {
+			fmt.Printf("%s does not get parsed: %v\n", item.Key, err)
+			continue
+		}
+		content, err := t.store.Get(item.Key)
+		if err != nil {
+			return nil, err
+		}
+		trashItem := models.TrashItem{
+			Key:       item.Key,
+			Name:      describeKey(key),
+			Text:      string(content),
+			DeletedAt: deletedAt,
+		}
+		if retention > 0 {
+			trashItem.PurgeAt = deletedAt.Add(retention)
+		}
+		trash = append(trash, trashItem)
+	}
+	sort.SliceStable(trash, func(i, j int) bool {
+		return trash[i].DeletedAt.After(trash[j].DeletedAt)
+	})
+	return trash, nil
+}
+
+// Restore puts the document back under the key it had, unless another document took that key in the meantime
+// returns the key of the restored document
+func (t *TrashService) Restore(trashKey string) (string, error) {
+	key, _, err := parseTrashKey(trashKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := t.store.Get(trashKey)
+	if err != nil {
+		return "", err
+	}
+	exists, err := storeHas(t.store, key)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", fmt.Errorf("cannot restore %s, it was created again since it was deleted", describeKey(key))
+	}
+	if err := t.store.Put(key, data); err != nil {
+		return "", err
+	}
+	if err := t.store.Delete(trashKey); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// Purge deletes the document from the trash for good, returns the key it had before it was deleted
+func (t *TrashService) Purge(trashKey string) (string, error) {
+	key, _, err := parseTrashKey(trashKey)
+	if err != nil {
+		return "", err
+	}
+	if err := t.store.Delete(trashKey); err != nil {
+		return "", err
+	}
+	return key, nil
+}
@@ -0,0 +1,213 @@
+package app
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+func TestMoveToTrash(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemoryStore() },
+		"file":   func(t *testing.T) Store { return NewFileStore(t.TempDir()) },
+	}
+	const key = "2025/dec-14.md"
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			if err := store.Put(key, []byte("text")); err != nil {
+				t.Fatal(err)
+			}
+			before := time.Now().UTC()
+			if err := moveToTrash(store, key); err != nil {
+				t.Fatal(err)
+			}
+			after := time.Now().UTC()
+
+			if _, err := store.Get(key); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Get of the trashed document = %v, want fs.ErrNotExist", err)
+			}
+			items, err := store.List(trashPrefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != 1 {
+				t.Fatalf("trash = %v, want one document", items)
+			}
+			// .trash/<time of the deletion>/<key>
+			stamp, trashedKey, _ := strings.Cut(strings.TrimPrefix(items[0].Key, trashPrefix+"/"), "/")
+			deletedAt, err := time.Parse(trashTimeFormat, stamp)
+			if err != nil || trashedKey != key || deletedAt.Before(before) || deletedAt.After(after) {
+				t.Errorf("trash key = %q, want %s/<time between %s and %s>/%s", items[0].Key, trashPrefix, before, after, key)
+			}
+			if data, err := store.Get(items[0].Key); err != nil || string(data) != "text" {
+				t.Errorf("trashed document = %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func TestParseTrashKey(t *testing.T) {
+	tests := []struct {
+		trashKey string
+		wantKey  string
+		wantErr  bool
+	}{
+		{".trash/20251214T093000.000000000/2025/dec-14.md", "2025/dec-14.md", false},
+		{".trash/20251214T093000.000000000/nav/shortcuts.md", "nav/shortcuts.md", false},
+		{".trash/20251214T093000.000000000/../2025/dec-14.md", "", true},
+		{".trash/20251214T093000.000000000", "", true},
+		{".trash/yesterday/2025/dec-14.md", "", true},
+		{"2025/dec-14.md", "", true},
+	}
+	for _, tt := range tests {
+		key, deletedAt, err := parseTrashKey(tt.trashKey)
+		if (err != nil) != tt.wantErr || key != tt.wantKey {
+			t.Errorf("parseTrashKey(%q) = %q, %v, want %q, error %v", tt.trashKey, key, err, tt.wantKey, tt.wantErr)
+		}
+		if want := time.Date(2025, 12, 14, 9, 30, 0, 0, time.UTC); err == nil && !deletedAt.Equal(want) {
+			t.Errorf("parseTrashKey(%q) deleted at %s, want %s", tt.trashKey, deletedAt, want)
+		}
+	}
+}
+
+func TestRestoreTrash(t *testing.T) {
+	a, do := newTestServer(t, NewMemoryStore())
+	sheet, err := a.sheetService.CreateSheet(Today().AddDate(0, 0, -1), "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := sheet.ID()
+	if err := a.sheetService.ReviewSheet(id, models.GradeGood); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.sheetService.DeleteSheet(id); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := a.trash.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Name != "sheet "+id || items[0].Text != "text" {
+		t.Fatalf("trash = %v, want the deleted sheet", items)
+	}
+	trashKey := items[0].Key
+
+	rec := do("POST", "/trash/restore", url.Values{"key": {trashKey}})
+	if failed(rec) || rec.Code != http.StatusSeeOther {
+		t.Fatalf("restore = %d %q, want a redirect", rec.Code, rec.Body.String())
+	}
+	restored, err := a.sheetService.GetSheetByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Text != "text" || !restored.Review.IsGraded() {
+		t.Errorf("restored sheet = %q graded %v, want the text with its state", restored.Text, restored.Review.IsGraded())
+	}
+	if items, _ := a.trash.List(); len(items) != 0 {
+		t.Errorf("trash after the restore = %v, want empty", items)
+	}
+
+	// the key was taken again while the sheet was in the trash
+	if err := a.sheetService.DeleteSheet(id); err != nil {
+		t.Fatal(err)
+	}
+	items, _ = a.trash.List()
+	key, _, _ := parseTrashKey(items[0].Key)
+	if err := a.store.Put(key, []byte("written again")); err != nil {
+		t.Fatal(err)
+	}
+	if rec := do("POST", "/trash/restore", url.Values{"key": {items[0].Key}}); !failed(rec) {
+		t.Errorf("restore over a taken key = %d, want the error box", rec.Code)
+	}
+	if data, _ := a.store.Get(key); string(data) != "written again" {
+		t.Errorf("document at the taken key = %q, want it kept", data)
+	}
+	if items, _ := a.trash.List(); len(items) != 1 {
+		t.Errorf("trash after the refused restore = %v, want the document kept", items)
+	}
+}
+
+func TestPurgeExpiredTrash(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	a := NewAppWithStore(store, "")
+	put := func(daysAgo int, key string) string {
+		t.Helper()
+		trashKey := trashPrefix + "/" + now.AddDate(0, 0, -daysAgo).UTC().Format(trashTimeFormat) + "/" + key
+		if err := store.Put(trashKey, []byte("text")); err != nil {
+			t.Fatal(err)
+		}
+		return trashKey
+	}
+	trashKeys := func() []string {
+		t.Helper()
+		items, err := a.trash.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for _, item := range items {
+			keys = append(keys, item.Key)
+		}
+		return keys
+	}
+
+	old := put(31, "2025/dec-1.md")
+	recent := put(29, "2025/dec-2.md")
+	if err := a.purgeExpiredTrash(now); err != nil {
+		t.Fatal(err)
+	}
+	if keys := trashKeys(); len(keys) != 1 || keys[0] != recent {
+		t.Errorf("trash past 30 days = %q, want only %q", keys, recent)
+	}
+	if _, err := store.Get(old); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Get of the purged document = %v, want fs.ErrNotExist", err)
+	}
+
+	// no retention keeps the documents until they are purged by hand
+	if err := a.SetTrashRetention(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.purgeExpiredTrash(now.AddDate(1, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if keys := trashKeys(); len(keys) != 1 {
+		t.Errorf("trash without retention = %q, want %q kept", keys, recent)
+	}
+	// a shorter retention purges right away
+	if err := a.SetTrashRetention(7 * 24 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if keys := trashKeys(); len(keys) != 0 {
+		t.Errorf("trash past 7 days = %q, want empty", keys)
+	}
+
+	// the state of a purged sheet goes with it
+	sheet, err := a.sheetService.CreateSheet(Today().AddDate(0, 0, -1), "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.sheetService.ReviewSheet(sheet.ID(), models.GradeGood); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.sheetService.DeleteSheet(sheet.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.sheetService.detached[sheet.ID()]; !ok {
+		t.Fatalf("the state of the trashed sheet %s is not kept", sheet.ID())
+	}
+	if err := a.purgeExpiredTrash(now.AddDate(0, 0, 8)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.sheetService.detached[sheet.ID()]; ok {
+		t.Errorf("the state of the purged sheet %s is still kept", sheet.ID())
+	}
+}
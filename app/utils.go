@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,76 +23,142 @@ func normalizeDate(date time.Time) time.Time {
 	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
 }
 
-// readFileContent reads the content of a file at the given path
-func readFileContent(path string) (string, error) {
-	content, err := os.ReadFile(path)
+// writeFileContent writes content to a file at the given path, atomically so a failed write keeps the old content
+// the directory of the file is created first, the first sheet of a year has no year directory yet
+func writeFileContent(path string, content string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, []byte(content), 0644)
+}
+
+// writeFileAtomic writes data to a temporary file next to path, syncs it and renames it over path
+// readers see either the old or the new content, never a partially written file, even after a crash or a full disk
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
 	if err != nil {
-		return "", err
+		return err
+	}
+	tmpPath := tmp.Name()
+	// clean up the temporary file unless it was renamed into place
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
 	}
-	return string(content), nil
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
 }
 
-// writeFileContent writes content to a file at the given path
-func writeFileContent(path string, content string) error {
-	// dir := filepath.Dir(path)
-	// if err := os.MkdirAll(dir, 0755); err != nil {
-	// 	return err
-	// }
-	return os.WriteFile(path, []byte(content), 0644)
+// syncDir flushes the entries of a directory, so a rename or removal in it survives a crash
+// it is best effort, not every platform can sync a directory
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
 }
 
 // deleteFile deletes a file at the given path
 func deleteFile(path string) error {
-	return os.Remove(path)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
 }
 
-// fileExists checks if a file exists at the given path
-func fileExists(path string) bool {
-	_, err := os.Stat(path)
-	return !os.IsNotExist(err)
+// sheetFilenamePattern matches the file name of a sheet without .md, such as jan-1 or the second sheet of the day jan-1-2
+var sheetFilenamePattern = regexp.MustCompile(`^([A-Za-z]{3})-(\d{1,2})(?:-(\d+))?$`)
+
+// parseSheetFilename parses a file name like "jan-1" or "jan-1-2" into its month abbreviation, day and sequence
+func parseSheetFilename(name string) (string, int, int, error) {
+	match := sheetFilenamePattern.FindStringSubmatch(name)
+	if match == nil {
+		return "", 0, 0, fmt.Errorf("invalid month-day format: %s", name)
+	}
+	day, _ := strconv.Atoi(match[2])
+	seq := 1
+	if match[3] != "" {
+		seq, _ = strconv.Atoi(match[3])
+		if seq < 2 {
+			return "", 0, 0, fmt.Errorf("invalid sheet sequence: %s", name)
+		}
+	}
+	return match[1], day, seq, nil
 }
 
-// parseFilepathToSheet parses a filepath like "sheets/jan-01.md" or "sheets/2025/jan-01.md" into a MemorySheet
-func parseFilepathToSheet(dir string, path string) (*models.MemorySheet, error) {
-	// Get relative path from base directory
-	relPath, err := filepath.Rel(dir, path)
+// parseKeyToSheet parses a store key like "jan-01.md" or "2025/jan-01-2.md" into a MemorySheet, reading its content from the store
+func parseKeyToSheet(store Store, item StoreItem) (*models.MemorySheet, error) {
+	sheet, err := parseSheetKey(item)
+	if err != nil || sheet == nil {
+		return nil, err
+	}
+
+	// Read the content
+	content, err := store.Get(item.Key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get relative path: %v", err)
+		return nil, err
 	}
+	if err := sheet.SetText(string(content)); err != nil {
+		// keep the sheet readable, only its metadata is lost
+		fmt.Printf("%s front matter does not get parsed: %v\n", item.Key, err)
+		sheet.Text = string(content)
+	}
+	return sheet, nil
+}
+
+// parseSheetKey parses a store key into a MemorySheet without its content, keys of nav sheets give a nil sheet
+func parseSheetKey(item StoreItem) (*models.MemorySheet, error) {
 	// Remove .md extension
-	relPath = strings.TrimSuffix(relPath, ".md")
+	key := strings.TrimSuffix(item.Key, ".md")
 
 	var year int
 	var monthStr string
 	var day int
+	var seq int
+	var err error
 
 	// Try to parse as format: YYYY/month-day (old format with subdirectory)
-	parts := strings.Split(relPath, string(filepath.Separator))
+	parts := strings.Split(key, "/")
+	if parts[0] == "nav" {
+		// skip parsing if directory name is nav, meaning the nav sheets
+		return nil, nil
+	}
 	if len(parts) == 2 {
 		// Old format: YYYY/month-day
-		if parts[0] == "nav" {
-			// skip parsing if directory name is nav, meaning the nav sheets
-			return nil, nil
-		}
 		if _, err := fmt.Sscanf(parts[0], "%d", &year); err != nil {
-			return nil, fmt.Errorf("invalid year in filepath: %s", path)
+			return nil, fmt.Errorf("invalid year in key: %s", item.Key)
 		}
-		if _, err := fmt.Sscanf(parts[1], "%3s-%02d", &monthStr, &day); err != nil {
-			return nil, fmt.Errorf("invalid month-day format in filepath: %s", path)
+		if monthStr, day, seq, err = parseSheetFilename(parts[1]); err != nil {
+			return nil, fmt.Errorf("invalid month-day format in key: %s", item.Key)
 		}
 	} else if len(parts) == 1 {
 		// New format: month-day (directly in dir)
-		if _, err := fmt.Sscanf(parts[0], "%3s-%02d", &monthStr, &day); err != nil {
-			return nil, fmt.Errorf("invalid month-day format in filepath: %s", path)
-		}
-		// Get year from file modification time
-		info, err := os.Stat(path)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get file info: %v", err)
+		if monthStr, day, seq, err = parseSheetFilename(parts[0]); err != nil {
+			return nil, fmt.Errorf("invalid month-day format in key: %s", item.Key)
 		}
-		year = info.ModTime().Year()
+		// Get year from the modification time
+		year = item.ModTime.Year()
 	} else {
-		return nil, fmt.Errorf("invalid filepath format: %s", path)
+		return nil, fmt.Errorf("invalid key format: %s", item.Key)
 	}
 
 	// Convert month abbreviation to month number
@@ -108,15 +176,9 @@ func parseFilepathToSheet(dir string, path string) (*models.MemorySheet, error)
 
 	date := normalizeDate(time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
 
-	// Read file content
-	content, err := readFileContent(path)
-	if err != nil {
-		return nil, err
-	}
-
 	return &models.MemorySheet{
 		Date: date,
 		Year: year,
-		Text: content,
+		Seq:  seq,
 	}, nil
 }
@@ -0,0 +1,76 @@
+//go:build unix
+
+package app
+
+import (
+	"bytes"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// limitFileSize makes writes past size bytes of a file fail with EFBIG until the test ends,
+// a real failed write the way a full disk fails it
+func limitFileSize(t *testing.T, size uint64) {
+	t.Helper()
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_FSIZE, &limit); err != nil {
+		t.Skip(err)
+	}
+	// the signal would kill the test, the write returns the error once it is ignored
+	signal.Ignore(syscall.SIGXFSZ)
+	lowered := syscall.Rlimit{Cur: size, Max: limit.Max}
+	if err := syscall.Setrlimit(syscall.RLIMIT_FSIZE, &lowered); err != nil {
+		t.Skip(err)
+	}
+	t.Cleanup(func() {
+		syscall.Setrlimit(syscall.RLIMIT_FSIZE, &limit)
+		signal.Reset(syscall.SIGXFSZ)
+	})
+}
+
+func TestWriteFileAtomicFailure(t *testing.T) {
+	original := []byte("original content")
+	writes := map[string]func(dir string, data []byte) error{
+		"writeFileAtomic": func(dir string, data []byte) error {
+			return writeFileAtomic(filepath.Join(dir, "2025", "dec-14.md"), data, 0644)
+		},
+		"FileStore.Put": func(dir string, data []byte) error {
+			return NewFileStore(dir).Put("2025/dec-14.md", data)
+		},
+	}
+	for name, write := range writes {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "2025", "dec-14.md")
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, original, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			limitFileSize(t, 1024)
+			if err := write(dir, bytes.Repeat([]byte("x"), 4096)); err == nil {
+				t.Fatal("write past the file size limit succeeded")
+			}
+
+			if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, original) {
+				t.Errorf("file after the failed write = %q, %v, want %q", data, err, original)
+			}
+			entries, err := os.ReadDir(filepath.Dir(path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				var names []string
+				for _, entry := range entries {
+					names = append(names, entry.Name())
+				}
+				t.Errorf("files after the failed write = %q, want only dec-14.md", names)
+			}
+		})
+	}
+}
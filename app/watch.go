@@ -0,0 +1,40 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// watchStore keeps the sheets in memory in line with the documents changed outside the app,
+// such as sheets edited in an editor or synced from another machine, until ctx is done
+func (a *App) watchStore(ctx context.Context) error {
+	events, err := a.store.Watch(ctx)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for event := range events {
+			if err := a.reload(event.Key); err != nil {
+				fmt.Printf("Warning: failed to reload %s: %v\n", event.Key, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// reload hands the changed document to the service it belongs to
+// the app's own writes come back here as well, reloading them leaves the sheets as they are
+func (a *App) reload(key string) error {
+	switch {
+	case isTrashKey(key):
+		return nil
+	case key == stateKey:
+		return a.sheetService.ReloadStates()
+	case strings.HasPrefix(key, navPrefix+"/"):
+		return a.navSheetService.Reload(key)
+	case strings.HasSuffix(key, ".md"):
+		return a.sheetService.Reload(key)
+	}
+	return nil
+}
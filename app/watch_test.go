@@ -0,0 +1,32 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchReloadsEveryChangeOfABurst(t *testing.T) {
+	store := NewMemoryStore()
+	a := NewAppWithStore(store, "")
+
+	// the sheets are held while the burst comes in, the reloads cannot keep up with it
+	first := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	const changes = 200
+	a.sheetService.mu.Lock()
+	for i := range changes {
+		key := a.sheetService.fromDateToKey(first.AddDate(0, 0, i), 1)
+		if err := store.Put(key, []byte("synced")); err != nil {
+			a.sheetService.mu.Unlock()
+			t.Fatal(err)
+		}
+	}
+	a.sheetService.mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(a.sheetService.AllSheets()) < changes && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(a.sheetService.AllSheets()); n != changes {
+		t.Errorf("%d sheets reloaded, want %d", n, changes)
+	}
+}
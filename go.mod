@@ -5,6 +5,11 @@ go 1.23.0
 toolchain go1.24.11
 
 require (
-	github.com/a-h/templ v0.3.960 // indirect
-	github.com/yuin/goldmark v1.7.13 // indirect
+	github.com/a-h/templ v0.3.960
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.33
+	github.com/yuin/goldmark v1.7.13
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.34.0 // indirect
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/linn221/memory-sheets/app"
 	"github.com/linn221/memory-sheets/middlewares"
@@ -10,13 +12,55 @@ import (
 )
 
 func main() {
+	dbPath := flag.String("db", "", "run on the SQLite database at this path instead of the sheets directory")
+	migrate := flag.Bool("migrate", false, "copy the sheets directory and pattern.json into the -db database and exit")
+	export := flag.Bool("export", false, "write the -db database back to the sheets directory and pattern.json and exit")
+	trashDays := flag.Int("trash-days", 30, "purge deleted sheets from the trash after this many days, 0 keeps them until purged by hand")
+	flag.Parse()
+
+	var memoryApp *app.App
+	if *dbPath == "" {
+		if *migrate || *export {
+			panic("-migrate and -export need -db")
+		}
+		memoryApp = app.NewApp("sheets", "pattern.json")
+	} else {
+		store, err := app.OpenSQLiteStore(*dbPath)
+		if err != nil {
+			panic(err)
+		}
+		defer store.Close()
+
+		switch {
+		case *migrate:
+			n, err := app.MigrateToStore(store, "sheets", "pattern.json")
+			if err != nil {
+				panic(err)
+			}
+			fmt.Printf("migrated %d documents into %s\n", n, *dbPath)
+			return
+		case *export:
+			n, err := app.ExportFromStore(store, "sheets", "pattern.json")
+			if err != nil {
+				panic(err)
+			}
+			fmt.Printf("exported %d documents from %s\n", n, *dbPath)
+			return
+		}
+		// the patterns live in the database too
+		memoryApp = app.NewAppWithStore(store, "")
+	}
+
+	if err := memoryApp.SetTrashRetention(time.Duration(*trashDays) * 24 * time.Hour); err != nil {
+		fmt.Printf("Warning: failed to purge the trash: %v\n", err)
+	}
+
 	secretMd := secretmiddleware.New("http://localhost", "8033", "/secret", "/sheets", secretmiddleware.PersistentSecret("secret.txt"), func(magicLink string) {
 		// could decide to do either email or print to console
 		fmt.Println(magicLink)
 	})
-	app := app.NewApp("sheets", "pattern.json")
 	mux := http.NewServeMux()
-	app.SetupRoutes(mux)
+	memoryApp.SetupRoutes(mux)
 
 	fileHandler := http.StripPrefix("/static", http.FileServer(http.Dir("static")))
 	mux.Handle("/static/", fileHandler)
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// ForecastDay is a future date along with the sheets that come due on it
+type ForecastDay struct {
+	Date   time.Time
+	Sheets []*MemorySheet
+}
+
+func (d ForecastDay) DateStr() string {
+	return d.Date.Format(time.DateOnly)
+}
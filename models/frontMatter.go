@@ -0,0 +1,146 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const frontMatterFence = "---"
+
+// FrontMatter is the YAML metadata block at the top of a sheet, fenced by --- lines
+//
+//	---
+//	title: Window functions
+//	tags: [sql, postgres]
+//	pattern: intensive
+//	archived: false
+//	sources:
+//	  - https://www.postgresql.org/docs/current/tutorial-window.html
+//	---
+type FrontMatter struct {
+	Title    string   `yaml:"title,omitempty"`
+	Tags     []string `yaml:"tags,omitempty"`
+	Pattern  string   `yaml:"pattern,omitempty"`
+	Archived bool     `yaml:"archived,omitempty"`
+	Sources  []string `yaml:"sources,omitempty"`
+}
+
+// SplitFrontMatter splits text into the YAML between the front matter fences and the markdown body after them
+// ok is false when the text does not start with front matter, the body is then the whole text
+func SplitFrontMatter(text string) (frontMatter string, body string, ok bool) {
+	rest, found := strings.CutPrefix(text, frontMatterFence+"\n")
+	if !found {
+		rest, found = strings.CutPrefix(text, frontMatterFence+"\r\n")
+	}
+	if !found {
+		return "", text, false
+	}
+
+	offset := 0
+	for offset <= len(rest) {
+		end := strings.IndexByte(rest[offset:], '\n')
+		line := rest[offset:]
+		if end >= 0 {
+			line = rest[offset : offset+end]
+		}
+		if strings.TrimRight(line, "\r") == frontMatterFence {
+			body := ""
+			if end >= 0 {
+				body = rest[offset+end+1:]
+			}
+			return rest[:offset], body, true
+		}
+		if end < 0 {
+			break
+		}
+		offset += end + 1
+	}
+
+	// an opening fence without a closing one is not front matter
+	return "", text, false
+}
+
+// StripFrontMatter returns the markdown body of text without its front matter
+func StripFrontMatter(text string) string {
+	_, body, _ := SplitFrontMatter(text)
+	return body
+}
+
+// ParseFrontMatter parses the front matter of text, text without front matter has an empty FrontMatter
+func ParseFrontMatter(text string) (FrontMatter, error) {
+	var meta FrontMatter
+	raw, _, ok := SplitFrontMatter(text)
+	if !ok {
+		return meta, nil
+	}
+	if err := yaml.Unmarshal([]byte(raw), &meta); err != nil {
+		return FrontMatter{}, fmt.Errorf("invalid front matter: %v", err)
+	}
+	return meta, nil
+}
+
+// SetFrontMatterField sets a single field of the front matter of text and returns the new text
+// the other fields, their order and comments are kept as written; a nil value removes the field
+// text without front matter gets a new front matter block
+func SetFrontMatterField(text string, key string, value any) (string, error) {
+	raw, body, ok := SplitFrontMatter(text)
+	if !ok {
+		body = text
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(raw), &doc); err != nil {
+		return "", fmt.Errorf("invalid front matter: %v", err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	mapping := doc.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return "", fmt.Errorf("invalid front matter: expected key value pairs")
+	}
+
+	// find the existing field
+	index := -1
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			index = i
+			break
+		}
+	}
+
+	if value == nil {
+		if index >= 0 {
+			mapping.Content = append(mapping.Content[:index], mapping.Content[index+2:]...)
+		}
+	} else {
+		var valueNode yaml.Node
+		if err := valueNode.Encode(value); err != nil {
+			return "", err
+		}
+		if index >= 0 {
+			mapping.Content[index+1] = &valueNode
+		} else {
+			mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &valueNode)
+		}
+	}
+
+	if len(mapping.Content) == 0 {
+		// nothing left, drop the whole block
+		return body, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return frontMatterFence + "\n" + buf.String() + frontMatterFence + "\n" + body, nil
+}
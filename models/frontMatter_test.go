@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestSetFrontMatterField(t *testing.T) {
+	const written = "---\ntitle: Window functions\nsource: book # chapter 3\ntags: [sql, postgres]\n---\nbody"
+	tests := []struct {
+		name  string
+		text  string
+		key   string
+		value any
+		want  string
+	}{
+		{"no front matter", "body", "pattern", "intensive", "---\npattern: intensive\n---\nbody"},
+		{"no front matter, nothing to remove", "body", "pattern", nil, "body"},
+		{"new field goes last", written, "archived", true,
+			"---\ntitle: Window functions\nsource: book # chapter 3\ntags: [sql, postgres]\narchived: true\n---\nbody"},
+		{"field changed in place", written, "title", "Windows",
+			"---\ntitle: Windows\nsource: book # chapter 3\ntags: [sql, postgres]\n---\nbody"},
+		{"field removed", written, "title", nil, "---\nsource: book # chapter 3\ntags: [sql, postgres]\n---\nbody"},
+		{"last field removed drops the block", "---\npattern: intensive\n---\nbody", "pattern", nil, "body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SetFrontMatterField(tt.text, tt.key, tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("SetFrontMatterField(%q, %q, %v) = %q, want %q", tt.text, tt.key, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetFrontMatterFieldOfAMalformedBlock(t *testing.T) {
+	for _, text := range []string{
+		"---\ntags: [sql\n---\nbody",
+		"---\n- sql\n- postgres\n---\nbody",
+	} {
+		if got, err := SetFrontMatterField(text, "pattern", "intensive"); err == nil {
+			t.Errorf("SetFrontMatterField(%q) = %q, want an error", text, got)
+		}
+	}
+}
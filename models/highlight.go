@@ -0,0 +1,5 @@
+package models
+
+// Highlighter returns the byte ranges of text to highlight, in the form of regexp's FindAllStringIndex
+// it is run on the text of the rendered sheet rather than its markdown, so highlighting never breaks the markup
+type Highlighter func(text string) [][]int
@@ -1,15 +1,64 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPatternName is the reminder pattern of sheets that were not assigned one
+const DefaultPatternName = "default"
 
 type MemorySheet struct {
 	Date time.Time
 	Year int
+	// Seq tells the sheets of the same date apart, the first sheet of a day is 1
+	Seq int
+	// Text is the whole file content, front matter included
 	Text string
+	// Meta is parsed from the front matter of Text
+	Meta   FrontMatter
+	Review ReviewState
+	// Pattern is the reminder pattern assigned before sheets had front matter, the front matter pattern wins over it
+	Pattern string
+	// OverdueDays is how many days the review is late, only set on sheets looked up for reminding
+	OverdueDays int
+	// Highlight marks the matches of a search in the rendered text, only set on sheets returned by a search
+	Highlight Highlighter
+}
+
+// ID identifies the sheet in routes and in the state file
+// the first sheet of a day is just its date (2025-12-14), the next ones get their sequence appended (2025-12-14-2)
+func (s *MemorySheet) ID() string {
+	if s.Seq <= 1 {
+		return s.DateStr()
+	}
+	return fmt.Sprintf("%s-%d", s.DateStr(), s.Seq)
+}
+
+// ParseSheetID parses a sheet ID back into the date and sequence of the sheet
+func ParseSheetID(id string) (time.Time, int, error) {
+	if len(id) < len(time.DateOnly) {
+		return time.Time{}, 0, fmt.Errorf("invalid sheet id: %s", id)
+	}
+	date, err := time.Parse(time.DateOnly, id[:len(time.DateOnly)])
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid sheet id: %s", id)
+	}
+	rest := id[len(time.DateOnly):]
+	if rest == "" {
+		return date, 1, nil
+	}
+	seq, err := strconv.Atoi(strings.TrimPrefix(rest, "-"))
+	if !strings.HasPrefix(rest, "-") || err != nil || seq < 2 {
+		return time.Time{}, 0, fmt.Errorf("invalid sheet id: %s", id)
+	}
+	return date, seq, nil
 }
 
 func (s *MemorySheet) Url() string {
-	return "sheets/" + s.Date.Format(time.DateOnly)
+	return "/sheets/" + s.ID()
 }
 
 func (s *MemorySheet) DateStr() string {
@@ -17,6 +66,54 @@ func (s *MemorySheet) DateStr() string {
 
 }
 
+// SetText replaces the text of the sheet along with the metadata parsed from its front matter
+func (s *MemorySheet) SetText(text string) error {
+	meta, err := ParseFrontMatter(text)
+	if err != nil {
+		return err
+	}
+	s.Text = text
+	s.Meta = meta
+	return nil
+}
+
+// Body returns the markdown of the sheet without its front matter
+func (s *MemorySheet) Body() string {
+	return StripFrontMatter(s.Text)
+}
+
+// Tags returns the tags of the front matter and the inline #tags of the body, normalized and sorted
+func (s *MemorySheet) Tags() []string {
+	return mergeTags(s.Meta.Tags, ParseInlineTags(s.Body()))
+}
+
+func (s *MemorySheet) HasTag(tag string) bool {
+	return hasTag(s.Tags(), tag)
+}
+
+// IsArchived tells if the sheet is retired from reviews, it is kept and still shows up in searches
+func (s *MemorySheet) IsArchived() bool {
+	return s.Meta.Archived
+}
+
+// PatternName returns the name of the reminder pattern the sheet follows
+func (s *MemorySheet) PatternName() string {
+	if s.Meta.Pattern != "" {
+		return s.Meta.Pattern
+	}
+	if s.Pattern != "" {
+		return s.Pattern
+	}
+	return DefaultPatternName
+}
+
 func (s *MemorySheet) Title() string {
-	return s.Date.Format("Jan 2")
+	title := s.Date.Format("Jan 2")
+	if s.Seq > 1 {
+		title += fmt.Sprintf(" (%d)", s.Seq)
+	}
+	if s.Meta.Title != "" {
+		return title + " - " + s.Meta.Title
+	}
+	return title
 }
@@ -2,6 +2,35 @@ package models
 
 type NavSheet struct {
 	Title string
-	Text  string
+	// Text is the whole file content, front matter included
+	Text string
+	// Meta is parsed from the front matter of Text
+	Meta FrontMatter
+	// Highlight marks the matches of a search in the rendered text, only set on sheets returned by a search
+	Highlight Highlighter
 }
 
+// SetText replaces the text of the sheet along with the metadata parsed from its front matter
+func (s *NavSheet) SetText(text string) error {
+	meta, err := ParseFrontMatter(text)
+	if err != nil {
+		return err
+	}
+	s.Text = text
+	s.Meta = meta
+	return nil
+}
+
+// Body returns the markdown of the sheet without its front matter
+func (s *NavSheet) Body() string {
+	return StripFrontMatter(s.Text)
+}
+
+// Tags returns the tags of the front matter and the inline #tags of the body, normalized and sorted
+func (s *NavSheet) Tags() []string {
+	return mergeTags(s.Meta.Tags, ParseInlineTags(s.Body()))
+}
+
+func (s *NavSheet) HasTag(tag string) bool {
+	return hasTag(s.Tags(), tag)
+}
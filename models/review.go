@@ -0,0 +1,52 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Grade is the answer to "How well did you remember this?"
+type Grade string
+
+const (
+	GradeAgain Grade = "again"
+	GradeHard  Grade = "hard"
+	GradeGood  Grade = "good"
+	GradeEasy  Grade = "easy"
+)
+
+// Grades lists every grade from worst to best recall
+var Grades = []Grade{GradeAgain, GradeHard, GradeGood, GradeEasy}
+
+func ParseGrade(s string) (Grade, error) {
+	for _, grade := range Grades {
+		if string(grade) == s {
+			return grade, nil
+		}
+	}
+	return "", fmt.Errorf("invalid grade: %s", s)
+}
+
+// ReviewState is the per sheet scheduling history
+// a zero ReviewState means the sheet has never been graded
+type ReviewState struct {
+	Ease         float64   `json:"ease,omitempty"`
+	Interval     int       `json:"interval,omitempty"`
+	Repetitions  int       `json:"repetitions,omitempty"`
+	LastGrade    Grade     `json:"last_grade,omitempty"`
+	LastReviewed time.Time `json:"last_reviewed,omitzero"`
+	Due          time.Time `json:"due,omitzero"`
+	// ShiftDays moves the reminder pattern of a sheet that was never graded, by the days it was snoozed for
+	ShiftDays int `json:"shift_days,omitempty"`
+	// SnoozedUntil holds back the reminders of a sheet that was never graded until this date
+	SnoozedUntil time.Time `json:"snoozed_until,omitzero"`
+}
+
+func (r ReviewState) IsGraded() bool {
+	return r.LastGrade != ""
+}
+
+// IsSnoozed tells if the reminders of the sheet are held back on date
+func (r ReviewState) IsSnoozed(date time.Time) bool {
+	return date.Before(r.SnoozedUntil)
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// ReviewEntry records a single review of a sheet
+type ReviewEntry struct {
+	SheetID    string        `json:"sheet_id,omitempty"`
+	SheetDate  time.Time     `json:"sheet_date"`
+	ReviewedAt time.Time     `json:"reviewed_at"`
+	Outcome    Grade         `json:"outcome"`
+	TimeSpent  time.Duration `json:"time_spent"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Revision is a saved version of a sheet in its history
+type Revision struct {
+	Hash    string
+	Time    time.Time
+	Message string
+}
+
+func (r Revision) ShortHash() string {
+	if len(r.Hash) > 7 {
+		return r.Hash[:7]
+	}
+	return r.Hash
+}
@@ -0,0 +1,7 @@
+package models
+
+// SearchResult is a sheet or a nav sheet found by a search, exactly one of them is set
+type SearchResult struct {
+	Sheet    *MemorySheet
+	NavSheet *NavSheet
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// inlineTagRegex matches #tags in markdown, the # must start a word and be followed by a letter or digit
+// so "# Heading", "page#anchor" and the anchor links "[top](#top)" are not tags
+var inlineTagRegex = regexp.MustCompile(`(?:^|[\s\[])#([\p{L}\p{N}][\p{L}\p{N}_/-]*)`)
+
+// TagCount is a tag along with how many sheets carry it
+type TagCount struct {
+	Tag   string
+	Count int
+}
+
+// NormalizeTag lower cases the tag and drops a leading #
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// ParseInlineTags returns the #tags written in the markdown body, code blocks and code spans are skipped
+func ParseInlineTags(body string) []string {
+	var tags []string
+	inFence := false
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		for _, match := range inlineTagRegex.FindAllStringSubmatch(stripCodeSpans(line), -1) {
+			tags = append(tags, match[1])
+		}
+	}
+	return tags
+}
+
+// stripCodeSpans blanks out the `code spans` of a line
+func stripCodeSpans(line string) string {
+	parts := strings.Split(line, "`")
+	for i := 1; i < len(parts); i += 2 {
+		if i == len(parts)-1 {
+			// an unclosed backtick is plain text
+			break
+		}
+		parts[i] = ""
+	}
+	return strings.Join(parts, " ")
+}
+
+// mergeTags normalizes, dedupes and sorts the front matter tags and the inline tags
+func mergeTags(frontMatterTags []string, inlineTags []string) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, tag := range append(append([]string{}, frontMatterTags...), inlineTags...) {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// hasTag reports whether the normalized tag is one of tags
+func hasTag(tags []string, tag string) bool {
+	tag = NormalizeTag(tag)
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
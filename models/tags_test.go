@@ -0,0 +1,29 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseInlineTags(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"tags", "#sql and #go/concurrency", []string{"sql", "go/concurrency"}},
+		{"heading", "# Heading\n## Sub", nil},
+		{"anchor in a url", "see page#anchor", nil},
+		{"anchor links", "See [x](#window-functions) and [top](#top).\n#sql", []string{"sql"}},
+		{"tag as link text", "[#sql](https://example.com)", []string{"sql"}},
+		{"code span", "`#notatag` but #tag", []string{"tag"}},
+		{"code fence", "```\n#notatag\n```\n#tag", []string{"tag"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseInlineTags(tt.body); !slices.Equal(got, tt.want) {
+				t.Errorf("ParseInlineTags(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// TrashItem is a deleted sheet kept in the trash until it is restored or purged
+type TrashItem struct {
+	// Key identifies the item in the trash
+	Key string
+	// Name describes what was deleted, such as "sheet 2025-12-14" or "nav sheet shortcuts"
+	Name      string
+	Text      string
+	DeletedAt time.Time
+	// PurgeAt is when the item gets purged on its own, zero when the trash is only emptied by hand
+	PurgeAt time.Time
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ContentVersion identifies a version of a sheet's content, an edit made on an older version is refused
+// rather than overwriting the newer one
+func ContentVersion(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Version identifies the current content of the sheet
+func (s *MemorySheet) Version() string {
+	return ContentVersion(s.Text)
+}
+
+// Version identifies the current content of the nav sheet
+func (s *NavSheet) Version() string {
+	return ContentVersion(s.Text)
+}
@@ -0,0 +1,162 @@
+package views
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+// sheetHTML renders the markdown of a sheet, marking the matches of its search highlight if it has one
+func sheetHTML(markdown string, highlight models.Highlighter) string {
+	rendered := MarkdownToHTMLSafe(markdown)
+	if highlight == nil {
+		return rendered
+	}
+	return highlightHTML(rendered, highlight)
+}
+
+// snippetLength is about how many bytes of text a search result snippet shows
+const snippetLength = 200
+
+// sheetSnippet returns a short piece of the rendered text of a sheet around the first match of highlight,
+// or its beginning when nothing matches, as HTML with the matches marked
+func sheetSnippet(markdown string, highlight models.Highlighter) string {
+	text := plainText(MarkdownToHTMLSafe(markdown))
+	start := 0
+	if highlight != nil {
+		for _, match := range highlight(text) {
+			if match[0] < match[1] {
+				// leave some context before the match
+				start = max(match[0]-snippetLength/4, 0)
+				break
+			}
+		}
+	}
+	end := min(start+snippetLength, len(text))
+
+	// cut at spaces so words are not split, falling back to a rune boundary
+	if start > 0 {
+		if space := strings.IndexByte(text[start:end], ' '); space >= 0 {
+			start += space + 1
+		}
+		for start < end && !utf8.RuneStart(text[start]) {
+			start++
+		}
+	}
+	if end < len(text) {
+		if space := strings.LastIndexByte(text[start:end], ' '); space > 0 {
+			end = start + space
+		}
+		for end > start && !utf8.RuneStart(text[end]) {
+			end--
+		}
+	}
+
+	snippet := html.EscapeString(text[start:end])
+	if highlight != nil {
+		snippet = highlightText(snippet, highlight)
+	}
+	if start > 0 {
+		snippet = "… " + snippet
+	}
+	if end < len(text) {
+		snippet += " …"
+	}
+	return snippet
+}
+
+// inlineTags are the tags goldmark renders within a line of text
+var inlineTags = map[string]bool{"a": true, "em": true, "strong": true, "code": true, "del": true, "img": true}
+
+// plainText returns the text of rendered HTML on one line, tags taken out
+func plainText(rendered string) string {
+	var b strings.Builder
+	rest := rendered
+	for rest != "" {
+		if rest[0] == '<' {
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				break
+			}
+			// block tags end a word, inline ones like <em> sit within the text
+			name, _, _ := strings.Cut(strings.TrimLeft(rest[1:end], "/"), " ")
+			if !inlineTags[strings.TrimRight(name, "/")] {
+				b.WriteByte(' ')
+			}
+			rest = rest[end+1:]
+			continue
+		}
+		end := strings.IndexByte(rest, '<')
+		if end < 0 {
+			end = len(rest)
+		}
+		b.WriteString(html.UnescapeString(rest[:end]))
+		rest = rest[end:]
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// resultCount tells how many results a search found, limited when there were more than it shows
+func resultCount(total int, limited bool) string {
+	if limited {
+		return fmt.Sprintf("The first %d results, narrow the search to see the rest", total)
+	}
+	switch total {
+	case 0:
+		return "No results"
+	case 1:
+		return "1 result"
+	}
+	return fmt.Sprintf("%d results", total)
+}
+
+// highlightHTML wraps the matches of highlight in <mark>, looking only at the text between tags
+// so tags, attributes and links are left as they are; a match never spans two text nodes
+func highlightHTML(rendered string, highlight models.Highlighter) string {
+	var b strings.Builder
+	rest := rendered
+	for rest != "" {
+		if rest[0] == '<' {
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				b.WriteString(rest)
+				break
+			}
+			b.WriteString(rest[:end+1])
+			rest = rest[end+1:]
+			continue
+		}
+		end := strings.IndexByte(rest, '<')
+		if end < 0 {
+			end = len(rest)
+		}
+		b.WriteString(highlightText(rest[:end], highlight))
+		rest = rest[end:]
+	}
+	return b.String()
+}
+
+// highlightText marks the matches in an escaped text node, which is matched in its unescaped form
+func highlightText(escaped string, highlight models.Highlighter) string {
+	text := html.UnescapeString(escaped)
+	var b strings.Builder
+	last := 0
+	for _, match := range highlight(text) {
+		start, end := match[0], match[1]
+		// skip empty matches and ones overlapping the previous match
+		if start >= end || start < last {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[last:start]))
+		b.WriteString("<mark>" + html.EscapeString(text[start:end]) + "</mark>")
+		last = end
+	}
+	if last == 0 {
+		return escaped
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package views
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/linn221/memory-sheets/models"
+)
+
+// matching highlights the matches of the regex, the way a regex search does
+func matching(expr string) models.Highlighter {
+	re := regexp.MustCompile(expr)
+	return func(text string) [][]int {
+		return re.FindAllStringIndex(text, -1)
+	}
+}
+
+// ranges highlights the given byte ranges whatever the text is
+func ranges(matches ...[]int) models.Highlighter {
+	return func(text string) [][]int {
+		return matches
+	}
+}
+
+func TestHighlightHTML(t *testing.T) {
+	tests := []struct {
+		name      string
+		rendered  string
+		highlight models.Highlighter
+		want      string
+	}{
+		{"escaped text is matched unescaped", `<p>x &lt; y &amp;&amp; z</p>`, matching(`< y &&`),
+			`<p>x <mark>&lt; y &amp;&amp;</mark> z</p>`},
+		{"tags and attributes are left alone", `<p><a href="/go" title="go">go</a></p>`, matching(`go|href|p`),
+			`<p><a href="/go" title="go"><mark>go</mark></a></p>`},
+		{"comments are left alone", `<p>raw <!-- raw HTML omitted --></p>`, matching(`raw`),
+			`<p><mark>raw</mark> <!-- raw HTML omitted --></p>`},
+		{"a match does not span text nodes", `<p>go<em>lang</em></p>`, matching(`golang`), `<p>go<em>lang</em></p>`},
+		{"overlapping matches keep the first", `<p>abcdef</p>`, ranges([]int{0, 4}, []int{2, 6}),
+			`<p><mark>abcd</mark>ef</p>`},
+		{"empty matches are skipped", `<p>abc</p>`, ranges([]int{1, 1}, []int{2, 3}), `<p>ab<mark>c</mark></p>`},
+		{"no match", `<p>x &amp; y</p>`, matching(`z`), `<p>x &amp; y</p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightHTML(tt.rendered, tt.highlight); got != tt.want {
+				t.Errorf("highlightHTML(%q) = %q, want %q", tt.rendered, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSheetSnippet(t *testing.T) {
+	tests := []struct {
+		name      string
+		markdown  string
+		highlight models.Highlighter
+		want      string
+	}{
+		{"short sheet", "# Title\n\n`x<y` & [go](/go)", matching(`x<y`), "Title <mark>x&lt;y</mark> &amp; go"},
+		{"no highlight", "# Title\n\ntext", nil, "Title text"},
+		{"nothing matches shows the beginning", strings.Repeat("word ", 100), matching(`target`),
+			strings.TrimSpace(strings.Repeat("word ", 40)) + " …"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sheetSnippet(tt.markdown, tt.highlight); got != tt.want {
+				t.Errorf("sheetSnippet = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSheetSnippetBoundaries(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+	}{
+		{"words", strings.Repeat("word ", 100) + "target " + strings.Repeat("word ", 100)},
+		{"multi-byte words", strings.Repeat("日本語 ", 100) + "target " + strings.Repeat("ことば ", 100)},
+		{"multi-byte text without spaces", strings.Repeat("日本語", 100) + "target" + strings.Repeat("ことば", 100)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippet := sheetSnippet(tt.markdown, matching(`target`))
+			if !utf8.ValidString(snippet) {
+				t.Fatalf("snippet %q splits a character", snippet)
+			}
+			if !strings.Contains(snippet, "<mark>target</mark>") {
+				t.Errorf("snippet %q does not show the match", snippet)
+			}
+			text, found := strings.CutPrefix(snippet, "… ")
+			if !found {
+				t.Errorf("snippet %q does not start with an ellipsis", snippet)
+			}
+			text, found = strings.CutSuffix(text, " …")
+			if !found {
+				t.Errorf("snippet %q does not end with an ellipsis", snippet)
+			}
+			text = strings.NewReplacer("<mark>", "", "</mark>", "").Replace(text)
+			if len(text) > snippetLength {
+				t.Errorf("snippet of %d bytes, want at most %d", len(text), snippetLength)
+			}
+			// cut at spaces when the text has them
+			if strings.Contains(tt.markdown, " ") && !strings.Contains(" "+tt.markdown+" ", " "+text+" ") {
+				t.Errorf("snippet %q splits a word", text)
+			}
+		})
+	}
+}
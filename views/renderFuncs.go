@@ -26,20 +26,56 @@ func (vr *ViewRenderer) render(component templ.Component) error {
 	return component.Render(vr.ctx, vr.w)
 }
 
-func (vr *ViewRenderer) IndexPage(sheets []*models.MemorySheet, todaySheet *models.MemorySheet, navSheets []*models.NavSheet) error {
-	return vr.render(Index(sheets, todaySheet, navSheets))
+func (vr *ViewRenderer) IndexPage(sheets []*models.MemorySheet, todaySheets []*models.MemorySheet, navSheets []*models.NavSheet, tags []string, activeTag string, reviewedToday int) error {
+	return vr.render(Index(sheets, todaySheets, navSheets, tags, activeTag, reviewedToday))
+}
+
+func (vr *ViewRenderer) ShowTags(tags []models.TagCount) error {
+	return vr.render(TagsPage(tags))
+}
+
+func (vr *ViewRenderer) ShowTag(tag string, memorySheets []*models.MemorySheet, navSheets []*models.NavSheet) error {
+	return vr.render(TagPage(tag, memorySheets, navSheets))
 }
 
 func (vr *ViewRenderer) SheetComponent(sheet *models.MemorySheet) error {
 	return vr.render(SheetComponent(sheet))
 }
 
-func (vr *ViewRenderer) ShowEditSheet(date string, content string) error {
-	return vr.render(EditSheetForm(date, content))
+func (vr *ViewRenderer) ShowEditSheet(id string, content string) error {
+	return vr.render(EditSheetForm(id, content))
+}
+
+func (vr *ViewRenderer) ShowSheetConflict(id string, saved string, yours string, merged string) error {
+	return vr.render(EditConflictForm("/sheets/"+id, saved, yours, merged))
+}
+
+func (vr *ViewRenderer) ShowNavSheetConflict(title string, saved string, yours string, merged string) error {
+	return vr.render(EditConflictForm("/nav-sheets/"+title, saved, yours, merged))
+}
+
+func (vr *ViewRenderer) ShowChangePattern(names []string, current string, intervals string, reviewDays map[int]bool) error {
+	return vr.render(ChangePattern(names, current, intervals, reviewDays))
+}
+
+func (vr *ViewRenderer) ShowSheetPattern(sheet *models.MemorySheet, names []string) error {
+	return vr.render(SheetPatternForm(sheet, names))
+}
+
+func (vr *ViewRenderer) ShowForecast(forecast []models.ForecastDay, baseline []models.ForecastDay, days int, names []string, name string, candidate string) error {
+	return vr.render(ForecastPage(forecast, baseline, days, names, name, candidate))
+}
+
+func (vr *ViewRenderer) ShowSheetHistory(sheet *models.MemorySheet, reviews []models.ReviewEntry, revisions []models.Revision, enabled bool, from string, to string, diff string) error {
+	return vr.render(SheetHistoryPage(sheet, reviews, revisions, enabled, from, to, diff))
+}
+
+func (vr *ViewRenderer) ShowArchive(sheets []*models.MemorySheet) error {
+	return vr.render(ArchivePage(sheets))
 }
 
-func (vr *ViewRenderer) ShowChangePattern(selectedDays map[int]bool) error {
-	return vr.render(ChangePattern(selectedDays))
+func (vr *ViewRenderer) ShowTrash(items []models.TrashItem, retentionDays int) error {
+	return vr.render(TrashPage(items, retentionDays))
 }
 
 func (vr *ViewRenderer) SheetListingComponent(sheets []*models.MemorySheet) error {
@@ -62,8 +98,8 @@ func (vr *ViewRenderer) ShowEditNavSheet(title string, content string) error {
 	return vr.render(EditNavSheetForm(title, content))
 }
 
-func (vr *ViewRenderer) SearchResults(memorySheets []*models.MemorySheet, navSheets []*models.NavSheet) error {
-	return vr.render(SearchResults(memorySheets, navSheets))
+func (vr *ViewRenderer) SearchHits(results []models.SearchResult, query string, total int, limited bool, first bool, nextURL string) error {
+	return vr.render(SearchHits(results, query, total, limited, first, nextURL))
 }
 
 func Handler(handle func(vr *ViewRenderer) error) http.HandlerFunc {
@@ -75,6 +111,8 @@ func Handler(handle func(vr *ViewRenderer) error) http.HandlerFunc {
 		}
 		err := handle(&vr)
 		if err != nil {
+			// only the out of band status box gets swapped in, the target keeps its content
+			w.Header().Set("HX-Reswap", "none")
 			ErrorBox(err.Error()).Render(r.Context(), w)
 		}
 	}
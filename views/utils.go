@@ -2,7 +2,11 @@ package views
 
 import (
 	"bytes"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/linn221/memory-sheets/models"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
@@ -11,6 +15,15 @@ import (
 
 const dateFormat = "2006-01-02"
 
+// weekdays are the column headers of calendar views, starting from Sunday like time.Weekday
+var weekdays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// reviewVals returns the hx-vals of a grade button
+// shown_at is the time the sheet was rendered, used to log how long the review took
+func reviewVals(grade models.Grade) string {
+	return fmt.Sprintf(`{"grade": %q, "shown_at": %d}`, grade, time.Now().Unix())
+}
+
 var md = goldmark.New(
 	goldmark.WithExtensions(extension.GFM),
 	goldmark.WithParserOptions(
@@ -32,10 +45,30 @@ func MarkdownToHTML(markdown string) (string, error) {
 }
 
 // MarkdownToHTMLSafe converts markdown text to HTML, returning empty string on error
+// the front matter of the text is metadata and does not get rendered
 func MarkdownToHTMLSafe(markdown string) string {
-	html, err := MarkdownToHTML(markdown)
+	html, err := MarkdownToHTML(models.StripFrontMatter(markdown))
 	if err != nil {
 		return ""
 	}
 	return html
 }
+
+func shortHash(hash string) string {
+	return models.Revision{Hash: hash}.ShortHash()
+}
+
+// diffLineStyle colors the added and removed lines of a unified diff
+func diffLineStyle(line string) string {
+	switch {
+	case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		return "font-weight: bold;"
+	case strings.HasPrefix(line, "+"):
+		return "color: #27ae60;"
+	case strings.HasPrefix(line, "-"):
+		return "color: #c0392b;"
+	case strings.HasPrefix(line, "@@"):
+		return "color: #2980b9;"
+	}
+	return ""
+}